@@ -0,0 +1,518 @@
+package erc1271
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/holyheld/gaelogrus"
+)
+
+// Multicall3Address is the address Multicall3 is deployed at on most of the chains
+//
+// https://github.com/mds1/multicall
+var Multicall3Address = common.HexToAddress("0xcA11bde05977b3631167028862bE2a173976CA11")
+
+// DefaultBatchCallDataLimit is the default call data size budget (in bytes) of a single batch eth_call
+const DefaultBatchCallDataLimit = 128 * 1024
+
+// multicall3ABI is the subset of Multicall3 ABI used for batching
+const multicall3ABI = `[{"inputs":[{"components":[{"internalType":"address","name":"target","type":"address"},{"internalType":"bool","name":"allowFailure","type":"bool"},{"internalType":"bytes","name":"callData","type":"bytes"}],"internalType":"struct Multicall3.Call3[]","name":"calls","type":"tuple[]"}],"name":"aggregate3","outputs":[{"components":[{"internalType":"bool","name":"success","type":"bool"},{"internalType":"bytes","name":"returnData","type":"bytes"}],"internalType":"struct Multicall3.Result[]","name":"returnData","type":"tuple[]"}],"stateMutability":"payable","type":"function"}]`
+
+// multicall3 is parsed multicall3ABI
+var multicall3 = mustABI(multicall3ABI)
+
+// multicall3Call is Multicall3.Call3 struct
+type multicall3Call struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+// multicall3Result is Multicall3.Result struct
+type multicall3Result struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// ValidationRequest describes a single signature validation of the batch
+type ValidationRequest struct {
+	// Hash is the digest passed to isValidSignature(bytes32,bytes)
+	Hash common.Hash
+	// Data is passed to legacy isValidSignature(bytes,bytes) if enabled, Hash bytes are used if empty
+	Data      []byte
+	Signer    common.Address
+	Signature []byte
+}
+
+// NewMessageValidationRequest creates ValidationRequest for EIP-191 personal message
+func NewMessageValidationRequest(message []byte, signer common.Address, signature []byte) ValidationRequest {
+	return ValidationRequest{
+		Hash:      common.BytesToHash(accounts.TextHash(message)),
+		Data:      message,
+		Signer:    signer,
+		Signature: signature,
+	}
+}
+
+// data returns the data passed to legacy isValidSignature(bytes,bytes)
+func (r ValidationRequest) data() []byte {
+	if len(r.Data) == 0 {
+		return r.Hash.Bytes()
+	}
+
+	return r.Data
+}
+
+// WithMulticallAddress sets custom Multicall3 address used by ValidateBatch
+func (v *Validator) WithMulticallAddress(address common.Address) *Validator {
+	v.multicallAddress = address
+	return v
+}
+
+// WithBatchCallDataLimit sets call data size budget (in bytes) of a single batch eth_call, requests exceeding it are
+// split into several calls
+func (v *Validator) WithBatchCallDataLimit(limit int) *Validator {
+	v.batchCallDataLimit = limit
+	return v
+}
+
+// WithBatchGasLimit sets gas budget of a single batch eth_call and the gas expected to be spent by a single
+// isValidSignature call, requests exceeding the budget are split into several calls, zero values disable the limit
+func (v *Validator) WithBatchGasLimit(batchGas uint64, callGas uint64) *Validator {
+	v.batchGas = batchGas
+	v.batchCallGas = callGas
+	return v
+}
+
+// ValidateBatch validates the requests aggregating isValidSignature calls through Multicall3 aggregate3 (and CodeAt
+// checks through a single deployless extcodesize call), falls back to per-request validation if Multicall3 is not
+// deployed on the chain, Safe mode, ERC-165 check or gas limit is enabled
+//
+// Gas limit set with WithGasLimit can not be applied to the calls aggregated by Multicall3 (every call gets all the
+// remaining gas), so a few gas burning wallets would fail the whole chunk
+//
+// Results are returned in the order of the requests, per-request failures are reported in ValidationResult.Err.
+// Note that msg.sender of the aggregated calls is Multicall3, not the signer
+func (v *Validator) ValidateBatch(ctx context.Context, requests []ValidationRequest) []ValidationResult {
+	logger := gaelogrus.GetLogger(ctx).WithField("func", "ValidateBatch")
+	results := make([]ValidationResult, len(requests))
+	fail := func(err error) []ValidationResult {
+		for i := range results {
+			results[i].Err = err
+		}
+		return results
+	}
+
+	if len(requests) == 0 {
+		return results
+	}
+
+	template, err := v.newResult(ctx, common.Hash{}, common.Address{})
+	if err != nil {
+		return fail(err)
+	}
+	blockNumber := template.BlockNumber
+
+	if v.safeMode != SafeModeDisabled || v.erc165Policy != ERC165PolicyDisabled || v.gasLimit != 0 {
+		logger.Debug("safe mode, erc165 check or gas limit is enabled, falling back to per-request validation")
+		return v.pinned(blockNumber).validateEach(ctx, requests, results)
+	}
+
+	deployed, err := v.isContractAt(ctx, v.multicallAddress, blockNumber)
+	if err != nil {
+		logger.WithError(err).Warn("failed to check if multicall is deployed")
+		return fail(err)
+	}
+
+	if !deployed {
+		logger.WithField("address", v.multicallAddress).Debug("multicall is not deployed, falling back to per-request validation")
+		return v.pinned(blockNumber).validateEach(ctx, requests, results)
+	}
+
+	pending := make([]int, 0, len(requests))
+	for i, request := range requests {
+		results[i] = *template
+		results[i].Digest, results[i].Signer, results[i].ValidatorAddress = request.Hash, request.Signer, request.Signer
+		if !IsZeroAddress(v.validatorAddress) {
+			results[i].ValidatorAddress = v.validatorAddress
+		}
+
+		if IsERC6492Signature(request.Signature) {
+			results[i].Err = v.validateERC6492(ctx, &results[i], request.data(), request.Signature)
+			v.finalizeBatchResult(&results[i])
+			continue
+		}
+
+		pending = append(pending, i)
+	}
+
+	if !v.skipIsContractCheck {
+		if err := v.batchCheckContracts(ctx, results, pending, blockNumber); err != nil {
+			logger.WithError(err).Warn("failed to check if validator addresses are contracts")
+			for _, i := range pending {
+				results[i].Err = err
+			}
+			return results
+		}
+
+		contracts := pending[:0]
+		for _, i := range pending {
+			if results[i].AccountKind == AccountKindDelegatedEOA {
+				results[i].Err = v.verifyDelegated(ctx, &results[i], requests[i].data(), requests[i].Signature)
+				v.finalizeBatchResult(&results[i])
+				continue
+			}
+
+			if results[i].IsContract {
+				contracts = append(contracts, i)
+				continue
+			}
+
+			results[i].Method = VerificationMethodERC1271
+			results[i].Status = ValidationStatusNotContract
+			v.finalizeBatchResult(&results[i])
+		}
+		pending = contracts
+	}
+
+	parsed, err := ContractMetaData.GetAbi()
+	if err != nil {
+		return fail(err)
+	}
+
+	calls := make([][]byte, len(pending))
+	for j, i := range pending {
+		results[i].Method = VerificationMethodERC1271
+		if calls[j], err = parsed.Pack("isValidSignature", requests[i].Hash, requests[i].Signature); err != nil {
+			return fail(err)
+		}
+	}
+
+	if err := v.aggregate(ctx, results, pending, calls, v.sig, blockNumber); err != nil {
+		for _, i := range pending {
+			results[i].Err = err
+		}
+		return results
+	}
+
+	if v.legacyFallback {
+		if err := v.batchLegacyFallback(ctx, requests, results, pending, blockNumber); err != nil {
+			for _, i := range pending {
+				results[i].Err = err
+			}
+			return results
+		}
+	}
+
+	for _, i := range pending {
+		v.finalizeBatchResult(&results[i])
+	}
+
+	if err := v.checkCanonical(ctx, blockNumber, template.BlockHash); err != nil {
+		return fail(err)
+	}
+
+	return results
+}
+
+// validateEach validates the requests one by one, concurrently if the client coalesces requests (BatchCaller)
+func (v *Validator) validateEach(ctx context.Context, requests []ValidationRequest, results []ValidationResult) []ValidationResult {
+	validate := func(i int) {
+		request := requests[i]
+		res, err := v.validateHash(ctx, request.Hash, request.data(), request.Signer, request.Signature)
+		if res != nil {
+			results[i] = *res
+		}
+		results[i].Err = err
+	}
+
+	if _, ok := v.client.(*BatchCaller); !ok {
+		for i := range requests {
+			validate(i)
+		}
+		return results
+	}
+
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			validate(i)
+		}(i)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// pinned returns the Validator pinned to already resolved block number, so per-request validations do not resolve it
+// again (Validator pinned to the block hash is kept as is, so the requests are still checked against the hash)
+func (v *Validator) pinned(blockNumber *big.Int) *Validator {
+	if blockNumber == nil || v.block.hash != nil {
+		return v
+	}
+
+	return v.AtBlockNumber(blockNumber)
+}
+
+// finalizeBatchResult converts contract related failure recorded in the result into error in strict mode
+func (v *Validator) finalizeBatchResult(res *ValidationResult) {
+	if res.Err == nil {
+		res.Err = v.strictError(res)
+	}
+}
+
+// batchLegacyFallback retries not valid results with legacy isValidSignature(bytes,bytes) calls
+func (v *Validator) batchLegacyFallback(ctx context.Context, requests []ValidationRequest, results []ValidationResult, pending []int, blockNumber *big.Int) error {
+	legacyParsed, err := LegacyContractMetaData.GetAbi()
+	if err != nil {
+		return err
+	}
+
+	var retry []int
+	var calls [][]byte
+	for _, i := range pending {
+		if results[i].Valid {
+			continue
+		}
+
+		callData, err := legacyParsed.Pack("isValidSignature", requests[i].data(), requests[i].Signature)
+		if err != nil {
+			return err
+		}
+
+		retry = append(retry, i)
+		calls = append(calls, callData)
+	}
+
+	legacyResults := make([]ValidationResult, len(results))
+	for _, i := range retry {
+		legacyResults[i] = results[i]
+		legacyResults[i].Method = VerificationMethodERC1271Legacy
+		legacyResults[i].ReturnValue, legacyResults[i].RevertData, legacyResults[i].RevertReason = nil, nil, ""
+	}
+
+	if err := v.aggregate(ctx, legacyResults, retry, calls, v.legacySig, blockNumber); err != nil {
+		return err
+	}
+
+	for _, i := range retry {
+		if legacyResults[i].Valid {
+			results[i] = legacyResults[i]
+		}
+	}
+
+	return nil
+}
+
+// aggregate performs calls to validator addresses of results[indexes] through Multicall3 aggregate3 in chunks and
+// records the outcome in the results
+func (v *Validator) aggregate(ctx context.Context, results []ValidationResult, indexes []int, calls [][]byte, magicValue []byte, blockNumber *big.Int) error {
+	for start := 0; start < len(indexes); {
+		end, size := start, 0
+		for end < len(indexes) && (end == start || v.fitsBatch(end-start+1, size+len(calls[end]))) {
+			size += len(calls[end])
+			end++
+		}
+
+		chunk := make([]multicall3Call, 0, end-start)
+		for j := start; j < end; j++ {
+			chunk = append(chunk, multicall3Call{Target: results[indexes[j]].ValidatorAddress, AllowFailure: true, CallData: calls[j]})
+		}
+
+		callData, err := multicall3.Pack("aggregate3", chunk)
+		if err != nil {
+			return err
+		}
+
+		to := v.multicallAddress
+		out, err := v.callContract(ctx, ethereum.CallMsg{To: &to, Gas: v.batchGas, Data: callData}, blockNumber)
+		if err != nil {
+			return &RPCError{Method: "eth_call", Err: err}
+		}
+
+		unpacked, err := multicall3.Unpack("aggregate3", out)
+		if err != nil {
+			return err
+		}
+
+		returned := *abi.ConvertType(unpacked[0], new([]multicall3Result)).(*[]multicall3Result)
+		if len(returned) != end-start {
+			return errors.New("unexpected multicall result length")
+		}
+
+		for j, result := range returned {
+			res := &results[indexes[start+j]]
+			if !result.Success {
+				res.Status = ValidationStatusReverted
+				res.RevertData = result.ReturnData
+				if reason, err := abi.UnpackRevert(result.ReturnData); err == nil {
+					res.RevertReason = reason
+				}
+				continue
+			}
+
+			res.setReturnValue(result.ReturnData, magicValue)
+		}
+
+		start = end
+	}
+
+	return nil
+}
+
+// batchCheckContracts records if validator addresses of results[indexes] have code, code sizes are fetched in chunks
+// with deployless eth_call returning extcodesize of every address
+func (v *Validator) batchCheckContracts(ctx context.Context, results []ValidationResult, indexes []int, blockNumber *big.Int) error {
+	var addresses []common.Address
+	sizes := make(map[common.Address]uint64, len(indexes))
+	seen := make(map[common.Address]bool, len(indexes))
+	for _, i := range indexes {
+		address := results[i].ValidatorAddress
+		if seen[address] {
+			continue
+		}
+		seen[address] = true
+
+		if hasCode, ok := v.cachedHasCode(address, blockNumber); ok {
+			if hasCode {
+				sizes[address] = 1
+			}
+			continue
+		}
+		addresses = append(addresses, address)
+	}
+
+	chunkSize := v.batchCallDataLimit / codeSizeCodeLength
+	if chunkSize < 1 {
+		chunkSize = 1
+	}
+	if chunkSize > maxCodeSizesChunk {
+		chunkSize = maxCodeSizesChunk
+	}
+
+	for start := 0; start < len(addresses); start += chunkSize {
+		end := start + chunkSize
+		if end > len(addresses) {
+			end = len(addresses)
+		}
+
+		chunkSizes, err := v.codeSizes(ctx, addresses[start:end], blockNumber)
+		if err != nil {
+			return err
+		}
+
+		for j, size := range chunkSizes {
+			sizes[addresses[start+j]] = size
+			if size != delegationCodeLength {
+				v.cacheHasCode(addresses[start+j], blockNumber, size > 0)
+			}
+		}
+	}
+
+	// code of delegation designator size may be EIP-7702 delegation, such accounts are classified one by one
+	delegates := make(map[common.Address]common.Address)
+	for address, size := range sizes {
+		if size != delegationCodeLength {
+			continue
+		}
+
+		kind, delegate, err := v.accountAt(ctx, address, blockNumber)
+		if err != nil {
+			return err
+		}
+		if kind == AccountKindDelegatedEOA {
+			delegates[address] = delegate
+		}
+	}
+
+	for _, i := range indexes {
+		results[i].ContractCheckPerformed = true
+		results[i].IsContract = sizes[results[i].ValidatorAddress] > 0
+		if !results[i].IsContract {
+			results[i].AccountKind = AccountKindEOA
+			continue
+		}
+
+		results[i].AccountKind = AccountKindContract
+		if delegate, ok := delegates[results[i].ValidatorAddress]; ok {
+			results[i].AccountKind, results[i].Delegate = AccountKindDelegatedEOA, delegate
+		}
+	}
+
+	return nil
+}
+
+// codeSizeCodeLength is the length of codeSizesCode per address
+const codeSizeCodeLength = 1 + common.AddressLength + 1 + 3 + 1
+
+// maxCodeSizesChunk is the maximal number of addresses codeSizesCode is built for: the sizes are returned as runtime
+// code of the deployless call, which EIP-170 limits to 24576 bytes (768 words), this also keeps PUSH2 offsets in range
+// and the creation code under EIP-3860 limit
+const maxCodeSizesChunk = 24576 / 32
+
+// codeSizes fetches extcodesize of the addresses with a single deployless eth_call
+func (v *Validator) codeSizes(ctx context.Context, addresses []common.Address, blockNumber *big.Int) ([]uint64, error) {
+	out, err := v.callContract(ctx, ethereum.CallMsg{Data: codeSizesCode(addresses)}, blockNumber)
+	if err != nil {
+		return nil, &RPCError{Method: "eth_call", Err: err}
+	}
+
+	if len(out) != 32*len(addresses) {
+		return nil, errors.New("unexpected code sizes result length")
+	}
+
+	sizes := make([]uint64, len(addresses))
+	for i := range addresses {
+		sizes[i] = new(big.Int).SetBytes(out[32*i : 32*(i+1)]).Uint64()
+	}
+
+	return sizes, nil
+}
+
+// codeSizesCode builds contract creation code to be executed with eth_call without "to", which returns
+// abi.encode(extcodesize(addresses[0]), ..., extcodesize(addresses[n-1]))
+func codeSizesCode(addresses []common.Address) []byte {
+	code := make([]byte, 0, len(addresses)*codeSizeCodeLength+6)
+	for i, address := range addresses {
+		// MSTORE(32 * i, EXTCODESIZE(address))
+		code = append(append(code, 0x73), address.Bytes()...)
+		code = append(code, 0x3b, 0x61, byte(32*i>>8), byte(32*i), 0x52)
+	}
+
+	// RETURN(0, 32 * n)
+	size := 32 * len(addresses)
+	return append(code, 0x61, byte(size>>8), byte(size), 0x60, 0x00, 0xf3)
+}
+
+// fitsBatch tells if the batch of calls with total call data size fits into configured budgets
+func (v *Validator) fitsBatch(calls int, callDataSize int) bool {
+	if v.batchCallDataLimit > 0 && callDataSize > v.batchCallDataLimit {
+		return false
+	}
+
+	if v.batchGas > 0 && v.batchCallGas > 0 && uint64(calls)*v.batchCallGas > v.batchGas {
+		return false
+	}
+
+	return true
+}
+
+// mustABI parses JSON ABI, panics on malformed value
+func mustABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}
@@ -0,0 +1,193 @@
+package erc1271
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestValidateBatch(t *testing.T) {
+	ctx := context.Background()
+
+	hash := crypto.Keccak256Hash([]byte("Hello go test!"))
+	wallet := common.HexToAddress("0x607377F587B1BDc68Bec3E19316D56bA8929d5eB")
+	revertingWallet := common.HexToAddress("0x8Ba1f109551bD432803012645Ac136ddd64DBA72")
+	eoa := common.HexToAddress("0x0000000000000000000000000000000000000e0a")
+
+	code := map[common.Address][]byte{
+		Multicall3Address: {0x60, 0x80},
+		wallet:            {0x60, 0x80},
+		revertingWallet:   {0x60, 0x80},
+	}
+
+	// isValidSignature answers single calls, returns magic value only for hash signed with 0x01
+	isValidSignature := func(to common.Address, data []byte) ([]byte, bool) {
+		if to == revertingWallet {
+			return nil, false
+		}
+
+		if bytes.Equal(data[4:36], hash.Bytes()) && bytes.Equal(data[len(data)-32:], common.RightPadBytes([]byte{0x01}, 32)) {
+			return common.RightPadBytes(ValidSignature, 32), true
+		}
+		return common.RightPadBytes([]byte{0xff, 0xff, 0xff, 0xff}, 32), true
+	}
+
+	var calls int
+	client := &mockCaller{
+		code: code,
+		call: func(msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+			calls++
+			if msg.To == nil {
+				statedb, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+				if err != nil {
+					return nil, err
+				}
+				for address, c := range code {
+					statedb.SetCode(address, c)
+				}
+
+				out, _, _, err := runtime.Create(msg.Data, &runtime.Config{State: statedb})
+				return out, err
+			}
+
+			if *msg.To != Multicall3Address {
+				out, ok := isValidSignature(*msg.To, msg.Data)
+				if !ok {
+					return nil, errors.New("execution reverted")
+				}
+				return out, nil
+			}
+
+			args, err := multicall3.Methods["aggregate3"].Inputs.Unpack(msg.Data[4:])
+			if err != nil {
+				return nil, err
+			}
+
+			var results []multicall3Result
+			for _, call := range *abi.ConvertType(args[0], new([]multicall3Call)).(*[]multicall3Call) {
+				out, ok := isValidSignature(call.Target, call.CallData)
+				results = append(results, multicall3Result{Success: ok, ReturnData: out})
+			}
+
+			return multicall3.Methods["aggregate3"].Outputs.Pack(results)
+		},
+	}
+
+	type Case struct {
+		Description string
+		Request     ValidationRequest
+		Valid       bool
+		Status      ValidationStatus
+	}
+
+	tests := []Case{
+		{"Valid signature", ValidationRequest{Hash: hash, Signer: wallet, Signature: []byte{0x01}}, true, ValidationStatusValid},
+		{"Invalid signature", ValidationRequest{Hash: hash, Signer: wallet, Signature: []byte{0x02}}, false, ValidationStatusInvalidMagicValue},
+		{"Reverted call", ValidationRequest{Hash: hash, Signer: revertingWallet, Signature: []byte{0x01}}, false, ValidationStatusReverted},
+		{"Not a contract", ValidationRequest{Hash: hash, Signer: eoa, Signature: []byte{0x01}}, false, ValidationStatusNotContract},
+	}
+
+	requests := make([]ValidationRequest, len(tests))
+	for i, test := range tests {
+		requests[i] = test.Request
+	}
+
+	type Setup struct {
+		Description   string
+		Validator     *Validator
+		ExpectedCalls int
+	}
+
+	setups := []Setup{
+		{"Single batch", NewValidator(client), 2},
+		{"Chunked batch", NewValidator(client).WithBatchGasLimit(100_000, 50_000), 3},
+		{"Multicall is not deployed", NewValidator(client).WithMulticallAddress(common.HexToAddress("0x01")), 3},
+		{"Gas limit", NewValidator(client).WithGasLimit(100_000), 3},
+	}
+
+	for _, setup := range setups {
+		calls = 0
+		results := setup.Validator.ValidateBatch(ctx, requests)
+
+		if len(results) != len(tests) {
+			t.Fatalf("%s: expected %d results, got: %d", setup.Description, len(tests), len(results))
+		}
+
+		for i, test := range tests {
+			if results[i].Err != nil {
+				t.Errorf("%s: %d (%s): expected err to be nil, got: %s", setup.Description, i, test.Description, results[i].Err)
+				continue
+			}
+
+			if results[i].Valid != test.Valid {
+				t.Errorf("%s: %d (%s): expected result to be %t, got: %t", setup.Description, i, test.Description, test.Valid, results[i].Valid)
+			}
+
+			if results[i].Status != test.Status {
+				t.Errorf("%s: %d (%s): expected status to be %s, got: %s", setup.Description, i, test.Description, test.Status, results[i].Status)
+			}
+		}
+
+		if calls != setup.ExpectedCalls {
+			t.Errorf("%s: expected %d eth_call requests, got: %d", setup.Description, setup.ExpectedCalls, calls)
+		}
+	}
+}
+
+func TestBatchCheckContractsChunking(t *testing.T) {
+	ctx := context.Background()
+
+	// more distinct signers than the code sizes of a single deployless call fit into EIP-170 limit
+	code := make(map[common.Address][]byte)
+	results := make([]ValidationResult, 2*maxCodeSizesChunk+10)
+	indexes := make([]int, len(results))
+	for i := range results {
+		results[i].ValidatorAddress = common.BigToAddress(big.NewInt(int64(0x1000 + i)))
+		if i%3 == 0 {
+			code[results[i].ValidatorAddress] = []byte{0x60, 0x80}
+		}
+		indexes[i] = i
+	}
+
+	var calls int
+	client := &mockCaller{
+		code: code,
+		call: func(msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+			calls++
+			statedb, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+			if err != nil {
+				return nil, err
+			}
+			for address, c := range code {
+				statedb.SetCode(address, c)
+			}
+
+			out, _, _, err := runtime.Create(msg.Data, &runtime.Config{State: statedb})
+			return out, err
+		},
+	}
+
+	if err := NewValidator(client).batchCheckContracts(ctx, results, indexes, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 3 {
+		t.Errorf("expected %d eth_call requests, got: %d", 3, calls)
+	}
+
+	for i, res := range results {
+		if res.IsContract != (i%3 == 0) {
+			t.Errorf("%d (%s): expected contract check to be %t, got: %t", i, res.ValidatorAddress.Hex(), i%3 == 0, res.IsContract)
+		}
+	}
+}
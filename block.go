@@ -0,0 +1,265 @@
+package erc1271
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Block tags accepted by WithBlockTag and AtBlockTag
+const (
+	BlockTagLatest    = "latest"
+	BlockTagPending   = "pending"
+	BlockTagSafe      = "safe"
+	BlockTagFinalized = "finalized"
+)
+
+// pendingBlockNumber is the block number go-ethereum clients translate into "pending" tag
+var pendingBlockNumber = big.NewInt(-1)
+
+// ErrBlockNotFound is returned when pinned block hash or tag can not be resolved
+var ErrBlockNotFound = errors.New("block not found")
+
+// RPCCaller is implemented by *rpc.Client, used for the requests bind.ContractCaller can not express
+type RPCCaller interface {
+	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+// headerReader is implemented by *ethclient.Client
+type headerReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error)
+}
+
+// blockSpec describes the block the state is queried at, zero value means latest (not pinned)
+type blockSpec struct {
+	number *big.Int
+	hash   *common.Hash
+	tag    string
+}
+
+// rpcBlock is the subset of eth_getBlockBy* response needed to pin the block
+type rpcBlock struct {
+	Number *hexutil.Big `json:"number"`
+	Hash   common.Hash  `json:"hash"`
+}
+
+// WithRPCClient sets raw JSON-RPC client used for the requests bind.ContractCaller can not express (e.g. resolving
+// safe and finalized block tags)
+func (v *Validator) WithRPCClient(client RPCCaller) *Validator {
+	v.rpcClient = client
+	return v
+}
+
+// WithBlockNumber pins both CodeAt check and isValidSignature call to the block number
+func (v *Validator) WithBlockNumber(number *big.Int) *Validator {
+	v.block = blockSpec{number: number}
+	return v
+}
+
+// WithBlockHash pins both CodeAt check and isValidSignature call to the block with the hash
+//
+// If raw JSON-RPC client is set with WithRPCClient, the requests are sent with EIP-1898 block hash argument requiring
+// the block to be canonical. Otherwise client must implement HeaderByHash and HeaderByNumber (i.e. *ethclient.Client),
+// the hash is resolved to the block number and checked to be still canonical after the requests, so a reorg fails
+// the validation instead of silently validating against another block
+func (v *Validator) WithBlockHash(hash common.Hash) *Validator {
+	v.block = blockSpec{hash: &hash}
+	return v
+}
+
+// WithBlockTag pins both CodeAt check and isValidSignature call to the block tag (BlockTagLatest, BlockTagPending,
+// BlockTagSafe or BlockTagFinalized)
+//
+// Latest, safe and finalized tags are resolved to the block number on every validation, so both requests hit the same
+// block; safe and finalized tags require raw JSON-RPC client to be set with WithRPCClient
+func (v *Validator) WithBlockTag(tag string) *Validator {
+	v.block = blockSpec{tag: tag}
+	return v
+}
+
+// AtBlockNumber returns a copy of the Validator pinned to the block number, original Validator is not modified
+func (v *Validator) AtBlockNumber(number *big.Int) *Validator {
+	return v.clone().WithBlockNumber(number)
+}
+
+// AtBlockHash returns a copy of the Validator pinned to the block hash, original Validator is not modified
+func (v *Validator) AtBlockHash(hash common.Hash) *Validator {
+	return v.clone().WithBlockHash(hash)
+}
+
+// AtBlockTag returns a copy of the Validator pinned to the block tag, original Validator is not modified
+func (v *Validator) AtBlockTag(tag string) *Validator {
+	return v.clone().WithBlockTag(tag)
+}
+
+// clone returns a shallow copy of the Validator
+func (v *Validator) clone() *Validator {
+	c := *v
+	return &c
+}
+
+// resolveBlock resolves configured block into the block number (and hash, if known) to query the state at
+//
+// Nil number means latest (not pinned), -1 means pending
+func (v *Validator) resolveBlock(ctx context.Context) (*big.Int, common.Hash, error) {
+	switch {
+	case v.block.number != nil:
+		return v.block.number, common.Hash{}, nil
+	case v.block.hash != nil:
+		return v.resolveBlockHash(ctx, *v.block.hash)
+	case v.block.tag != "":
+		return v.resolveBlockTag(ctx, v.block.tag)
+	default:
+		return nil, common.Hash{}, nil
+	}
+}
+
+// resolveBlockHash resolves block hash into the block number
+func (v *Validator) resolveBlockHash(ctx context.Context, hash common.Hash) (*big.Int, common.Hash, error) {
+	if v.rpcClient != nil {
+		return v.getBlock(ctx, "eth_getBlockByHash", hash)
+	}
+
+	reader, ok := v.client.(headerReader)
+	if !ok {
+		return nil, common.Hash{}, errors.New("resolving block hash requires HeaderByHash support or raw rpc client")
+	}
+
+	header, err := reader.HeaderByHash(ctx, hash)
+	if err != nil {
+		return nil, common.Hash{}, &RPCError{Method: "eth_getBlockByHash", Err: err}
+	}
+
+	return header.Number, header.Hash(), nil
+}
+
+// resolveBlockTag resolves block tag into the block number, pending tag is passed as is
+func (v *Validator) resolveBlockTag(ctx context.Context, tag string) (*big.Int, common.Hash, error) {
+	switch tag {
+	case BlockTagPending:
+		return pendingBlockNumber, common.Hash{}, nil
+	case BlockTagLatest, BlockTagSafe, BlockTagFinalized:
+	default:
+		return nil, common.Hash{}, fmt.Errorf("unsupported block tag: %q", tag)
+	}
+
+	if v.rpcClient != nil {
+		return v.getBlock(ctx, "eth_getBlockByNumber", tag)
+	}
+
+	reader, ok := v.client.(headerReader)
+	if !ok || tag != BlockTagLatest {
+		return nil, common.Hash{}, fmt.Errorf("resolving %q block tag requires raw rpc client", tag)
+	}
+
+	header, err := reader.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, common.Hash{}, &RPCError{Method: "eth_getBlockByNumber", Err: err}
+	}
+
+	return header.Number, header.Hash(), nil
+}
+
+// blockHashArg returns EIP-1898 block argument pinning the request to the canonical block with the hash
+//
+// https://eips.ethereum.org/EIPS/eip-1898
+func blockHashArg(hash common.Hash) interface{} {
+	return map[string]interface{}{"blockHash": hash, "requireCanonical": true}
+}
+
+// pinnedToHash tells if the state requests are pinned to the block hash with EIP-1898 block argument
+func (v *Validator) pinnedToHash() bool {
+	return v.block.hash != nil && v.rpcClient != nil
+}
+
+// blockArg returns JSON-RPC block argument of the state request
+func (v *Validator) blockArg(blockNumber *big.Int) interface{} {
+	if v.pinnedToHash() {
+		return blockHashArg(*v.block.hash)
+	}
+
+	return toBlockNumArg(blockNumber)
+}
+
+// stateCaller returns the client the state is queried with, requests pinned to the block hash are sent with raw rpc
+// client (state override client pins the requests itself)
+func (v *Validator) stateCaller() bind.ContractCaller {
+	if _, ok := v.client.(*overrideCaller); ok || !v.pinnedToHash() {
+		return v.client
+	}
+
+	return &blockHashCaller{rpcClient: v.rpcClient, hash: *v.block.hash}
+}
+
+// checkCanonical makes sure the block the hash was resolved to is still canonical after the state was queried by its
+// number, requests sent with EIP-1898 block argument are checked by the node
+func (v *Validator) checkCanonical(ctx context.Context, blockNumber *big.Int, blockHash common.Hash) error {
+	if v.block.hash == nil || v.rpcClient != nil {
+		return nil
+	}
+
+	reader, ok := v.client.(headerReader)
+	if !ok {
+		return errors.New("resolving block hash requires HeaderByHash support or raw rpc client")
+	}
+
+	header, err := reader.HeaderByNumber(ctx, blockNumber)
+	if err != nil {
+		return &RPCError{Method: "eth_getBlockByNumber", Err: err}
+	}
+
+	if header.Hash() != blockHash {
+		return fmt.Errorf("%w: %s is not canonical", ErrBlockNotFound, blockHash.Hex())
+	}
+
+	return nil
+}
+
+// blockHashCaller is bind.ContractCaller querying the state at the canonical block with the hash, block number
+// arguments are ignored
+type blockHashCaller struct {
+	rpcClient RPCCaller
+	hash      common.Hash
+}
+
+// CodeAt implements bind.ContractCaller
+func (c *blockHashCaller) CodeAt(ctx context.Context, contract common.Address, _ *big.Int) ([]byte, error) {
+	var code hexutil.Bytes
+	if err := c.rpcClient.CallContext(ctx, &code, "eth_getCode", contract, blockHashArg(c.hash)); err != nil {
+		return nil, err
+	}
+
+	return code, nil
+}
+
+// CallContract implements bind.ContractCaller
+func (c *blockHashCaller) CallContract(ctx context.Context, msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	var result hexutil.Bytes
+	if err := c.rpcClient.CallContext(ctx, &result, "eth_call", toCallArg(msg), blockHashArg(c.hash)); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// getBlock fetches block number and hash using raw rpc client
+func (v *Validator) getBlock(ctx context.Context, method string, arg interface{}) (*big.Int, common.Hash, error) {
+	var block *rpcBlock
+	if err := v.rpcClient.CallContext(ctx, &block, method, arg, false); err != nil {
+		return nil, common.Hash{}, &RPCError{Method: method, Err: err}
+	}
+
+	if block == nil || block.Number == nil {
+		return nil, common.Hash{}, fmt.Errorf("%w: %v", ErrBlockNotFound, arg)
+	}
+
+	return block.Number.ToInt(), block.Hash, nil
+}
@@ -0,0 +1,206 @@
+package erc1271
+
+import (
+	"container/list"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// CodeCache caches whether the addresses have code, used by the Validator to skip eth_getCode requests
+//
+// Implementations must be safe for concurrent use
+type CodeCache interface {
+	// Get returns cached value and true, or false if the address is not cached (or expired)
+	Get(address common.Address) (hasCode bool, ok bool)
+	// Set caches the value for the address
+	Set(address common.Address, hasCode bool)
+}
+
+// CacheStats is the snapshot of the cache hit/miss counters
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// cacheCounters are the cache hit/miss counters shared between the Validator copies
+type cacheCounters struct {
+	hits   uint64
+	misses uint64
+}
+
+// record increments hit or miss counter
+func (c *cacheCounters) record(hit bool) {
+	if hit {
+		atomic.AddUint64(&c.hits, 1)
+		return
+	}
+
+	atomic.AddUint64(&c.misses, 1)
+}
+
+// stats returns the snapshot of the counters
+func (c *cacheCounters) stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+
+	return CacheStats{Hits: atomic.LoadUint64(&c.hits), Misses: atomic.LoadUint64(&c.misses)}
+}
+
+// lruCache is in-memory cache evicting least recently used entries, entries expire after per-entry TTL
+type lruCache struct {
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[interface{}]*list.Element
+	order   *list.List
+}
+
+// lruEntry is the value of lruCache list element
+type lruEntry struct {
+	key       interface{}
+	value     interface{}
+	expiresAt time.Time
+}
+
+// newLRUCache creates a new lruCache instance holding up to size entries
+func newLRUCache(size int) *lruCache {
+	return &lruCache{
+		size:    size,
+		now:     time.Now,
+		entries: make(map[interface{}]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get returns cached value and true, or false if the key is not cached (or expired)
+func (c *lruCache) get(key interface{}) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*lruEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	return entry.value, true
+}
+
+// set caches the value for the key, evicts least recently used entries if the cache is full
+func (c *lruCache) set(key interface{}, value interface{}, ttl time.Duration) {
+	if ttl <= 0 || c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &lruEntry{key: key, value: value, expiresAt: c.now().Add(ttl)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// len returns the number of cached entries (including expired ones not evicted yet)
+func (c *lruCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+// LRUCodeCache is in-memory CodeCache evicting least recently used entries, entries expire after TTL, addresses
+// without code expire after (usually shorter) negative TTL, so newly deployed (counterfactual) wallets are picked up
+type LRUCodeCache struct {
+	cache       *lruCache
+	ttl         time.Duration
+	negativeTTL time.Duration
+}
+
+// NewLRUCodeCache creates a new LRUCodeCache instance holding up to size entries
+func NewLRUCodeCache(size int, ttl time.Duration, negativeTTL time.Duration) *LRUCodeCache {
+	return &LRUCodeCache{
+		cache:       newLRUCache(size),
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+	}
+}
+
+// Get implements CodeCache
+func (c *LRUCodeCache) Get(address common.Address) (bool, bool) {
+	value, ok := c.cache.get(address)
+	if !ok {
+		return false, false
+	}
+
+	return value.(bool), true
+}
+
+// Set implements CodeCache
+func (c *LRUCodeCache) Set(address common.Address, hasCode bool) {
+	ttl := c.ttl
+	if !hasCode {
+		ttl = c.negativeTTL
+	}
+
+	c.cache.set(address, hasCode, ttl)
+}
+
+// Len returns the number of cached entries (including expired ones not evicted yet)
+func (c *LRUCodeCache) Len() int {
+	return c.cache.len()
+}
+
+// WithCodeCache sets the cache used by CodeAt checks, only the checks at the latest (not pinned) state are cached
+func (v *Validator) WithCodeCache(cache CodeCache) *Validator {
+	v.codeCache = cache
+	v.codeCacheCounters = &cacheCounters{}
+	return v
+}
+
+// CodeCacheStats returns code cache hit/miss counters
+func (v *Validator) CodeCacheStats() CacheStats {
+	return v.codeCacheCounters.stats()
+}
+
+// cachedHasCode returns cached value if the check at the block number can be answered from the cache
+func (v *Validator) cachedHasCode(address common.Address, blockNumber *big.Int) (bool, bool) {
+	if v.codeCache == nil || blockNumber != nil {
+		return false, false
+	}
+
+	hasCode, ok := v.codeCache.Get(address)
+	v.codeCacheCounters.record(ok)
+	return hasCode, ok
+}
+
+// cacheHasCode caches the result of the check at the block number
+func (v *Validator) cacheHasCode(address common.Address, blockNumber *big.Int, hasCode bool) {
+	if v.codeCache == nil || blockNumber != nil {
+		return
+	}
+
+	v.codeCache.Set(address, hasCode)
+}
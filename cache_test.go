@@ -0,0 +1,91 @@
+package erc1271
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestLRUCodeCache(t *testing.T) {
+	now := time.Unix(0, 0)
+	cache := NewLRUCodeCache(2, time.Minute, time.Second)
+	cache.cache.now = func() time.Time { return now }
+
+	contract := common.HexToAddress("0x01")
+	eoa := common.HexToAddress("0x02")
+	other := common.HexToAddress("0x03")
+
+	cache.Set(contract, true)
+	cache.Set(eoa, false)
+
+	type Case struct {
+		Description string
+		Elapsed     time.Duration
+		Address     common.Address
+		HasCode     bool
+		Cached      bool
+	}
+
+	tests := []Case{
+		{"Cached contract", 0, contract, true, true},
+		{"Cached address without code", 0, eoa, false, true},
+		{"Expired address without code", 2 * time.Second, eoa, false, false},
+		{"Not expired contract", 0, contract, true, true},
+		{"Not cached address", 0, other, false, false},
+		{"Expired contract", time.Minute, contract, false, false},
+	}
+
+	for i, test := range tests {
+		now = now.Add(test.Elapsed)
+		hasCode, ok := cache.Get(test.Address)
+		if ok != test.Cached {
+			t.Errorf("%d (%s): expected cached to be %t, got: %t", i, test.Description, test.Cached, ok)
+		}
+
+		if hasCode != test.HasCode {
+			t.Errorf("%d (%s): expected result to be %t, got: %t", i, test.Description, test.HasCode, hasCode)
+		}
+	}
+
+	cache.Set(contract, true)
+	cache.Set(eoa, true)
+	cache.Get(contract)
+	cache.Set(other, true)
+	if _, ok := cache.Get(eoa); ok {
+		t.Errorf("expected least recently used entry to be evicted")
+	}
+
+	if cache.Len() != 2 {
+		t.Errorf("expected cache length to be 2, got: %d", cache.Len())
+	}
+}
+
+func TestValidatorCodeCache(t *testing.T) {
+	ctx := context.Background()
+	wallet := common.HexToAddress("0x607377F587B1BDc68Bec3E19316D56bA8929d5eB")
+
+	client := &mockCaller{code: map[common.Address][]byte{wallet: {0x60, 0x80}}}
+	validator := NewValidator(client).WithCodeCache(NewLRUCodeCache(16, time.Minute, time.Second))
+
+	for i := 0; i < 3; i++ {
+		if _, err := validator.IsContract(ctx, wallet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := validator.AtBlockNumber(big.NewInt(1)).IsContract(ctx, wallet); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(client.codeBlocks) != 2 {
+		t.Errorf("expected 2 CodeAt calls, got: %d", len(client.codeBlocks))
+	}
+
+	stats := validator.CodeCacheStats()
+	if stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("expected 2 hits and 1 miss, got: %+v", stats)
+	}
+}
@@ -0,0 +1,93 @@
+package erc1271
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// WithGasLimit bounds gas available to isValidSignature execution, so a malicious or buggy wallet can not burn the
+// node's gas cap, running out of gas is reported as reverted call
+//
+// Intrinsic gas of the call (21000 base gas and call data cost) is added to the limit, so large signatures do not
+// fail with intrinsic gas too low. The same applies to Safe, ERC-7739 and Inspect calls
+//
+// ERC6492 deployless calls are not bounded as they include the wallet deployment, ValidateBatch falls back to
+// per-request validation since Multicall3 can not bound the aggregated calls
+func (v *Validator) WithGasLimit(gas uint64) *Validator {
+	v.gasLimit = gas
+	return v
+}
+
+// WithCallFrom sets msg.sender of isValidSignature call, signer address is used by default
+func (v *Validator) WithCallFrom(from common.Address) *Validator {
+	v.callFrom = &from
+	return v
+}
+
+// WithCallOpts applies bind.CallOpts: From (if not zero) is set as msg.sender of isValidSignature call, BlockNumber
+// and Pending pin the block the state is queried at, Context is ignored (validate methods accept it explicitly)
+func (v *Validator) WithCallOpts(opts *bind.CallOpts) *Validator {
+	if opts == nil {
+		return v
+	}
+
+	if !IsZeroAddress(opts.From) {
+		v.WithCallFrom(opts.From)
+	}
+
+	switch {
+	case opts.Pending:
+		v.WithBlockTag(BlockTagPending)
+	case opts.BlockNumber != nil:
+		v.WithBlockNumber(opts.BlockNumber)
+	}
+
+	return v
+}
+
+// WithCallTimeout sets timeout of every eth_getCode and eth_call request (every attempt if retries are enabled)
+func (v *Validator) WithCallTimeout(timeout time.Duration) *Validator {
+	v.callTimeout = timeout
+	return v
+}
+
+// callGas returns gas limit of eth_call making execution gas available to the call data execution, zero execution gas
+// means node's gas cap
+func callGas(executionGas uint64, callData []byte) uint64 {
+	if executionGas == 0 {
+		return 0
+	}
+
+	gas := params.TxGas + executionGas
+	for _, b := range callData {
+		if b == 0 {
+			gas += params.TxDataZeroGas
+		} else {
+			gas += params.TxDataNonZeroGasEIP2028
+		}
+	}
+
+	return gas
+}
+
+// callSender returns msg.sender of isValidSignature call
+func (v *Validator) callSender(signer common.Address) common.Address {
+	if v.callFrom != nil {
+		return *v.callFrom
+	}
+
+	return signer
+}
+
+// callContext returns the context of a single request bounded by the call timeout
+func (v *Validator) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if v.callTimeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, v.callTimeout)
+}
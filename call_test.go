@@ -0,0 +1,127 @@
+package erc1271
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// slowCaller is bind.ContractCaller answering after delay unless the context is done
+type slowCaller struct {
+	delay time.Duration
+}
+
+func (s *slowCaller) CodeAt(ctx context.Context, _ common.Address, _ *big.Int) ([]byte, error) {
+	select {
+	case <-time.After(s.delay):
+		return []byte{0x60, 0x80}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *slowCaller) CallContract(_ context.Context, _ ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	return common.RightPadBytes(ValidSignature, 32), nil
+}
+
+// intrinsicGas returns 21000 base gas and call data cost the nodes charge before the execution
+func intrinsicGas(data []byte) uint64 {
+	gas := uint64(21_000)
+	for _, b := range data {
+		if b == 0 {
+			gas += 4
+		} else {
+			gas += 16
+		}
+	}
+	return gas
+}
+
+func TestValidatorCallOptions(t *testing.T) {
+	ctx := context.Background()
+	hash := crypto.Keccak256Hash([]byte("Hello go test!"))
+	wallet := common.HexToAddress("0x607377F587B1BDc68Bec3E19316D56bA8929d5eB")
+	sender := common.HexToAddress("0x0000000000000000000000000000000000005e4d")
+
+	var lastMsg ethereum.CallMsg
+	var lastBlock *big.Int
+	client := &mockCaller{
+		code: map[common.Address][]byte{wallet: {0x60, 0x80}},
+		call: func(msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+			lastMsg, lastBlock = msg, blockNumber
+			// the node charges intrinsic gas, isValidSignature execution takes 50000 gas
+			if msg.Gas > 0 && msg.Gas < intrinsicGas(msg.Data) {
+				return nil, errors.New("intrinsic gas too low")
+			}
+			if msg.Gas > 0 && msg.Gas-intrinsicGas(msg.Data) < 50_000 {
+				return nil, errors.New("out of gas")
+			}
+			return common.RightPadBytes(ValidSignature, 32), nil
+		},
+	}
+
+	largeSignature := bytes.Repeat([]byte{0x01}, 4096)
+
+	type Case struct {
+		Description string
+		Validator   *Validator
+		Signature   []byte
+		Valid       bool
+		Status      ValidationStatus
+		From        common.Address
+		// Gas is the execution gas of the call (without intrinsic gas)
+		Gas   uint64
+		Block *big.Int
+	}
+
+	tests := []Case{
+		{"Default options", NewValidator(client), []byte{0x01}, true, ValidationStatusValid, wallet, 0, nil},
+		{"Gas limit", NewValidator(client).WithGasLimit(100_000), []byte{0x01}, true, ValidationStatusValid, wallet, 100_000, nil},
+		{"Gas limit with large signature", NewValidator(client).WithGasLimit(60_000), largeSignature, true, ValidationStatusValid, wallet, 60_000, nil},
+		{"Gas limit exceeded", NewValidator(client).WithGasLimit(30_000), []byte{0x01}, false, ValidationStatusReverted, wallet, 30_000, nil},
+		{"Custom sender", NewValidator(client).WithCallFrom(sender), []byte{0x01}, true, ValidationStatusValid, sender, 0, nil},
+		{"Call opts", NewValidator(client).WithCallOpts(&bind.CallOpts{From: sender, BlockNumber: big.NewInt(7)}), []byte{0x01}, true, ValidationStatusValid, sender, 0, big.NewInt(7)},
+	}
+
+	for i, test := range tests {
+		res, err := test.Validator.ValidateHashDetailedBytes(ctx, hash, wallet, test.Signature)
+		if err != nil {
+			t.Errorf("%d (%s): expected err to be nil, got: %s", i, test.Description, err)
+			continue
+		}
+
+		if res.Valid != test.Valid || res.Status != test.Status {
+			t.Errorf("%d (%s): expected result to be %t (%s), got: %t (%s)", i, test.Description, test.Valid, test.Status, res.Valid, res.Status)
+		}
+
+		gas := lastMsg.Gas
+		if gas > 0 {
+			gas -= intrinsicGas(lastMsg.Data)
+		}
+		if lastMsg.From != test.From || gas != test.Gas {
+			t.Errorf("%d (%s): expected call from %s with %d gas, got: from %s with %d gas", i, test.Description, test.From.Hex(), test.Gas, lastMsg.From.Hex(), gas)
+		}
+
+		if (lastBlock == nil) != (test.Block == nil) || (lastBlock != nil && lastBlock.Cmp(test.Block) != 0) {
+			t.Errorf("%d (%s): expected block to be %v, got: %v", i, test.Description, test.Block, lastBlock)
+		}
+	}
+
+	// the call was not executed because of too low gas limit, the node is fine
+	if !IsExecutionError(errors.New("intrinsic gas too low: have 21000, want 21064")) {
+		t.Errorf("expected intrinsic gas error to be execution error")
+	}
+
+	_, err := NewValidator(&slowCaller{delay: time.Second}).WithCallTimeout(10*time.Millisecond).ValidateHashBytes(ctx, hash, wallet, []byte{0x01})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected err to be %s, got: %v", context.DeadlineExceeded, err)
+	}
+}
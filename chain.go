@@ -0,0 +1,59 @@
+package erc1271
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+)
+
+// chainIDReader is implemented by *ethclient.Client
+type chainIDReader interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+}
+
+// chainIDState memoizes chain ID fetched from the client, shared between the Validator copies
+type chainIDState struct {
+	mu      sync.Mutex
+	chainID *big.Int
+}
+
+// WithChainID sets chain ID of the client, otherwise it is fetched from the client (if it implements ChainID, i.e.
+// *ethclient.Client) once and memoized
+func (v *Validator) WithChainID(chainID *big.Int) *Validator {
+	v.chainIDState = &chainIDState{chainID: chainID}
+	return v
+}
+
+// ChainID returns configured chain ID, fetches it from the client if not set
+func (v *Validator) ChainID(ctx context.Context) (*big.Int, error) {
+	v.chainIDState.mu.Lock()
+	defer v.chainIDState.mu.Unlock()
+
+	if v.chainIDState.chainID != nil {
+		return v.chainIDState.chainID, nil
+	}
+
+	chainID, err := v.fetchChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	v.chainIDState.chainID = chainID
+	return chainID, nil
+}
+
+// fetchChainID fetches chain ID from the client
+func (v *Validator) fetchChainID(ctx context.Context) (*big.Int, error) {
+	reader, ok := v.client.(chainIDReader)
+	if !ok {
+		return nil, errors.New("chain id is not set and client does not support ChainID")
+	}
+
+	chainID, err := reader.ChainID(ctx)
+	if err != nil {
+		return nil, &RPCError{Method: "eth_chainId", Err: err}
+	}
+
+	return chainID, nil
+}
@@ -2,28 +2,48 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/holyheld/gaelogrus"
+	"io/ioutil"
+	"math/big"
 	"os"
+	"strings"
+	"time"
 
 	"flag"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 	"github.com/holyheld/erc1271"
 )
 
 func main() {
 	var message string
+	var typedDataPath string
 	var signer string
 	var signature string
 	var rpcURL string
 	var validatorAddress string
 	var customValidSignature string
+	var block string
+	var gasLimit uint64
+	var timeout time.Duration
+	var statePath string
+	var chainID uint64
+	var strict bool
+	var requireChecksum bool
 	var debug bool
 
 	flag.StringVar(&rpcURL, "rpc", "https://cloudflare-eth.com", "specifies rpc url explicitly")
 	flag.StringVar(&rpcURL, "r", "https://cloudflare-eth.com", "specifies rpc url explicitly (shorthand)")
 	flag.StringVar(&message, "message", "", "specifies message to be validated against")
 	flag.StringVar(&message, "m", "", "specifies message to be validated against (shorthand)")
+	flag.StringVar(&typedDataPath, "typed_data", "", "specifies path to EIP-712 typed data JSON file to be validated against (instead of message)")
+	flag.StringVar(&typedDataPath, "td", "", "specifies path to EIP-712 typed data JSON file to be validated against (instead of message) (shorthand)")
 	flag.StringVar(&signer, "address", "", "specifies signer address")
 	flag.StringVar(&signer, "a", "", "specifies signer address (shorthand)")
 	flag.StringVar(&signature, "signature", "", "specifies signature to validate")
@@ -33,6 +53,14 @@ func main() {
 	flag.StringVar(&validatorAddress, "v", "", "specifies validator address (must be contract address) (shorthand)")
 	flag.StringVar(&customValidSignature, "valid_signature", "", "specifies custom valid signature (successful response)")
 	flag.StringVar(&customValidSignature, "vs", "", "specifies custom valid signature (successful response) (shorthand)")
+	flag.StringVar(&block, "block", "", "specifies block to validate at: number, hash or tag (latest, pending, safe, finalized)")
+	flag.StringVar(&block, "b", "", "specifies block to validate at: number, hash or tag (latest, pending, safe, finalized) (shorthand)")
+	flag.Uint64Var(&gasLimit, "gas_limit", 0, "specifies gas limit of isValidSignature call (0 means node's gas cap)")
+	flag.DurationVar(&timeout, "timeout", 0, "specifies timeout of every rpc request (0 means no timeout)")
+	flag.StringVar(&statePath, "state", "", "specifies path to JSON state dump to validate against offline (instead of rpc)")
+	flag.Uint64Var(&chainID, "chain_id", 1, "specifies chain id of the state dump (offline mode only)")
+	flag.BoolVar(&requireChecksum, "checksum", false, "requires addresses to be EIP-55 checksummed")
+	flag.BoolVar(&strict, "strict", false, "enables strict mode (contract related failures are reported as errors)")
 	flag.BoolVar(&debug, "d", false, "enables debug comments (verbose)")
 
 	flag.Parse()
@@ -54,26 +82,113 @@ func main() {
 		os.Exit(2)
 	}
 
+	if _, err := erc1271.ParseAddress(signer, requireChecksum); err != nil {
+		logger.WithError(err).Error("invalid signer address provided")
+		os.Exit(2)
+	}
+
 	if signature == "" {
 		logger.Error("empty signature provided")
 		os.Exit(2)
 	}
 
-	client, err := ethclient.DialContext(ctx, rpcURL)
-	if err != nil {
-		logger.WithError(err).Fatalf("failed to validate signature")
+	if _, err := erc1271.ParseSignatureHex(signature); err != nil {
+		logger.WithError(err).Error("invalid signature provided")
+		os.Exit(2)
+	}
+
+	if validatorAddress != "" {
+		if _, err := erc1271.ParseAddress(validatorAddress, requireChecksum); err != nil {
+			logger.WithError(err).Error("invalid validator address provided")
+			os.Exit(2)
+		}
+	}
+
+	if customValidSignature != "" {
+		if _, err := erc1271.ParseMagicValueHex(customValidSignature); err != nil {
+			logger.WithError(err).Error("invalid custom valid signature provided")
+			os.Exit(2)
+		}
+	}
+
+	if message != "" && typedDataPath != "" {
+		logger.Error("message and typed data are mutually exclusive")
+		os.Exit(2)
+	}
+
+	var typedData *apitypes.TypedData
+	if typedDataPath != "" {
+		raw, err := ioutil.ReadFile(typedDataPath)
+		if err != nil {
+			logger.WithError(err).Fatalf("failed to read typed data file")
+		}
+
+		typedData = new(apitypes.TypedData)
+		if err := json.Unmarshal(raw, typedData); err != nil {
+			logger.WithError(err).Fatalf("failed to parse typed data file")
+		}
 	}
 
 	logger.WithFields(map[string]interface{}{
 		"signer":               signer,
 		"message":              message,
+		"typedDataPath":        typedDataPath,
 		"signature":            signature,
 		"rpcURL":               rpcURL,
 		"validatorAddress":     validatorAddress,
 		"customValidSignature": customValidSignature,
+		"block":                block,
+		"statePath":            statePath,
 	}).Debug("arguments")
 
-	validator := erc1271.NewValidator(client)
+	var validator *erc1271.Validator
+	if statePath != "" {
+		file, err := os.Open(statePath)
+		if err != nil {
+			logger.WithError(err).Fatalf("failed to read state dump file")
+		}
+
+		dump, err := erc1271.LoadStateDump(file)
+		file.Close()
+		if err != nil {
+			logger.WithError(err).Fatalf("failed to parse state dump file")
+		}
+
+		localClient, err := erc1271.NewLocalCaller(dump)
+		if err != nil {
+			logger.WithError(err).Fatalf("failed to load state dump")
+		}
+		validator = erc1271.NewValidator(localClient.WithChainID(new(big.Int).SetUint64(chainID)))
+	} else {
+		rpcClient, err := rpc.DialContext(ctx, rpcURL)
+		if err != nil {
+			logger.WithError(err).Fatalf("failed to validate signature")
+		}
+		validator = erc1271.NewValidator(ethclient.NewClient(rpcClient)).WithRPCClient(rpcClient)
+	}
+
+	validator = validator.
+		WithStrictMode(strict).
+		WithRequireChecksum(requireChecksum).
+		WithGasLimit(gasLimit).
+		WithCallTimeout(timeout)
+
+	if block != "" {
+		if number, ok := new(big.Int).SetString(block, 10); ok {
+			validator = validator.WithBlockNumber(number)
+		} else if strings.HasPrefix(block, "0x") || strings.HasPrefix(block, "0X") {
+			hash, err := hexutil.Decode(block)
+			if err == nil && len(hash) != common.HashLength {
+				err = fmt.Errorf("expected %d bytes, got %d", common.HashLength, len(hash))
+			}
+			if err != nil {
+				logger.WithError(err).Fatalf("invalid block hash")
+			}
+			validator = validator.WithBlockHash(common.BytesToHash(hash))
+		} else {
+			validator = validator.WithBlockTag(block)
+		}
+	}
 
 	if validatorAddress != "" {
 		validator = validator.WithValidatorAddressHex(validatorAddress)
@@ -83,15 +198,36 @@ func main() {
 		validator = validator.WithCustomValidSignatureHex(customValidSignature)
 	}
 
-	valid, err := validator.Validate(
-		ctx,
-		[]byte(message),
-		signer,
-		signature,
-	)
+	var res *erc1271.ValidationResult
+	var err error
+	if typedData != nil {
+		res, err = validator.ValidateTypedDataDetailed(
+			ctx,
+			*typedData,
+			signer,
+			signature,
+		)
+	} else {
+		res, err = validator.ValidateDetailed(
+			ctx,
+			[]byte(message),
+			signer,
+			signature,
+		)
+	}
 	if err != nil {
 		logger.WithError(err).Fatalf("failed to validate signature")
 	}
 
-	logger.WithField("valid", valid).Info("result")
+	logger.WithFields(map[string]interface{}{
+		"status":           res.Status.String(),
+		"method":           res.Method.String(),
+		"digest":           res.Digest.Hex(),
+		"validatorAddress": res.ValidatorAddress.Hex(),
+		"returnValue":      hexutil.Encode(res.ReturnValue),
+		"revertReason":     res.RevertReason,
+		"blockNumber":      res.BlockNumber,
+	}).Debug("details")
+
+	logger.WithField("valid", res.Valid).Info("result")
 }
@@ -1,6 +1,17 @@
 package erc1271
 
-import "github.com/ethereum/go-ethereum/crypto"
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
 
 // ValidSignature is a magic value to compare validate result against
 var ValidSignature = crypto.Keccak256([]byte("isValidSignature(bytes32,bytes)"))[:4]
+
+// LegacyValidSignature is a magic value to compare legacy isValidSignature(bytes,bytes) result against
+var LegacyValidSignature = crypto.Keccak256([]byte("isValidSignature(bytes,bytes)"))[:4]
+
+// ERC6492MagicSuffix is appended to signatures of counterfactual (not yet deployed) wallets
+//
+// https://eips.ethereum.org/EIPS/eip-6492
+var ERC6492MagicSuffix = common.FromHex("0x6492649264926492649264926492649264926492649264926492649264926492")
@@ -0,0 +1,138 @@
+package erc1271
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/holyheld/gaelogrus"
+)
+
+// DelegationPrefix is the prefix of EIP-7702 delegation designator, the code of delegated EOA is
+// 0xef0100 ‖ delegate address
+//
+// https://eips.ethereum.org/EIPS/eip-7702
+var DelegationPrefix = []byte{0xef, 0x01, 0x00}
+
+// delegationCodeLength is the length of EIP-7702 delegation designator
+const delegationCodeLength = 3 + common.AddressLength
+
+// AccountKind tells what kind of account the address is
+type AccountKind int
+
+const (
+	// AccountKindEOA is used when the address has no code
+	AccountKindEOA AccountKind = iota
+	// AccountKindContract is used when the address has contract code
+	AccountKindContract
+	// AccountKindDelegatedEOA is used when the address is EOA delegating to a contract with EIP-7702 designator
+	AccountKindDelegatedEOA
+)
+
+// String returns human-readable name of the account kind
+func (k AccountKind) String() string {
+	switch k {
+	case AccountKindContract:
+		return "contract"
+	case AccountKindDelegatedEOA:
+		return "delegated_eoa"
+	default:
+		return "eoa"
+	}
+}
+
+// DelegatedEOAPolicy tells how signatures of delegated EOAs are verified, they may be valid ECDSA signatures of the
+// EOA key as well as signatures accepted by isValidSignature of the delegate
+type DelegatedEOAPolicy int
+
+const (
+	// DelegatedEOAPolicyERC1271First calls isValidSignature, falls back to ecrecover if the delegate does not
+	// implement it (the call reverted or returned nothing)
+	DelegatedEOAPolicyERC1271First DelegatedEOAPolicy = iota
+	// DelegatedEOAPolicyEither accepts the signature if either ecrecover or isValidSignature accepts it, ECDSA
+	// signature of the EOA key is accepted without calling the delegate, the other signatures (including ECDSA
+	// signatures of other keys, i.e. the delegate's session keys) are checked with isValidSignature
+	DelegatedEOAPolicyEither
+)
+
+// ParseDelegation returns the delegate address if the code is EIP-7702 delegation designator
+func ParseDelegation(code []byte) (common.Address, bool) {
+	if len(code) != delegationCodeLength || !bytes.HasPrefix(code, DelegationPrefix) {
+		return common.Address{}, false
+	}
+
+	return common.BytesToAddress(code[len(DelegationPrefix):]), true
+}
+
+// classifyCode tells the account kind (and the delegate of delegated EOA) out of the code
+func classifyCode(code []byte) (AccountKind, common.Address) {
+	if len(code) == 0 {
+		return AccountKindEOA, common.Address{}
+	}
+
+	if delegate, ok := ParseDelegation(code); ok {
+		return AccountKindDelegatedEOA, delegate
+	}
+
+	return AccountKindContract, common.Address{}
+}
+
+// WithDelegatedEOAPolicy sets how signatures of EIP-7702 delegated EOAs are verified
+func (v *Validator) WithDelegatedEOAPolicy(policy DelegatedEOAPolicy) *Validator {
+	v.delegatedEOAPolicy = policy
+	return v
+}
+
+// ClassifyAccount tells if the address is EOA, contract or EIP-7702 delegated EOA (along with the delegate address)
+func (v *Validator) ClassifyAccount(ctx context.Context, address common.Address) (AccountKind, common.Address, error) {
+	blockNumber, _, err := v.resolveBlock(ctx)
+	if err != nil {
+		return AccountKindEOA, common.Address{}, err
+	}
+
+	return v.accountAt(ctx, address, blockNumber)
+}
+
+// verifyDelegated verifies signature of delegated EOA according to the configured policy
+func (v *Validator) verifyDelegated(ctx context.Context, res *ValidationResult, data []byte, signature []byte) error {
+	logger := gaelogrus.GetLogger(ctx).WithField("func", "verifyDelegated").WithField("address", res.ValidatorAddress)
+	switch v.delegatedEOAPolicy {
+	case DelegatedEOAPolicyEither:
+		if recoverResult(res, signature); res.Valid {
+			return nil
+		}
+
+		return v.isValidSignature(ctx, res, data, signature)
+	default:
+		if err := v.isValidSignature(ctx, res, data, signature); err != nil {
+			return err
+		}
+
+		if res.Valid || (res.Status != ValidationStatusReverted && len(res.ReturnValue) > 0) {
+			return nil
+		}
+
+		logger.Debug("delegate does not implement isValidSignature, falling back to ecrecover")
+		recoverResult(res, signature)
+		return nil
+	}
+}
+
+// recoverResult records the outcome of recovering validator address out of ECDSA signature in the result
+func recoverResult(res *ValidationResult, signature []byte) {
+	res.Method = VerificationMethodECRecover
+	res.ReturnValue, res.RevertData, res.RevertReason = nil, nil, ""
+	recovered, err := RecoverSigner(res.Digest, signature)
+	if err != nil {
+		res.Valid = false
+		res.Status = ValidationStatusMalformedSignature
+		return
+	}
+
+	res.Valid = recovered == res.ValidatorAddress
+	res.Status = ValidationStatusValid
+	if !res.Valid {
+		res.Status = ValidationStatusSignerMismatch
+	}
+}
@@ -0,0 +1,127 @@
+package erc1271
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestClassifyAccount(t *testing.T) {
+	ctx := context.Background()
+	eoa := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	contract := common.HexToAddress("0x0000000000000000000000000000000000000002")
+	delegated := common.HexToAddress("0x0000000000000000000000000000000000000003")
+	delegate := common.HexToAddress("0x63c0c19a282a1B52b07dD5a65b58948A07DAE32B")
+
+	client := &mockCaller{code: map[common.Address][]byte{
+		contract:  {0x60, 0x80},
+		delegated: append(common.CopyBytes(DelegationPrefix), delegate.Bytes()...),
+	}}
+
+	type Case struct {
+		Description string
+		Address     common.Address
+		Kind        AccountKind
+		Delegate    common.Address
+	}
+
+	tests := []Case{
+		{"EOA", eoa, AccountKindEOA, common.Address{}},
+		{"Contract", contract, AccountKindContract, common.Address{}},
+		{"Delegated EOA", delegated, AccountKindDelegatedEOA, delegate},
+	}
+
+	validator := NewValidator(client).WithCodeCache(NewLRUCodeCache(16, time.Minute, time.Minute))
+	for i, test := range tests {
+		kind, address, err := validator.ClassifyAccount(ctx, test.Address)
+		if err != nil {
+			t.Errorf("%d (%s): expected err to be nil, got: %s", i, test.Description, err)
+			continue
+		}
+
+		if kind != test.Kind || address != test.Delegate {
+			t.Errorf("%d (%s): expected result to be %s (%s), got: %s (%s)", i, test.Description, test.Kind, test.Delegate.Hex(), kind, address.Hex())
+		}
+	}
+}
+
+func TestValidateDelegatedEOA(t *testing.T) {
+	ctx := context.Background()
+	hash := crypto.Keccak256Hash([]byte("Hello go test!"))
+	delegate := common.HexToAddress("0x63c0c19a282a1B52b07dD5a65b58948A07DAE32B")
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := crypto.PubkeyToAddress(key.PublicKey)
+
+	sign := func(key *ecdsa.PrivateKey) []byte {
+		signature, err := crypto.Sign(hash.Bytes(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signature
+	}
+
+	accepting := func(ethereum.CallMsg, *big.Int) ([]byte, error) {
+		return common.RightPadBytes(ValidSignature, 32), nil
+	}
+	rejecting := func(ethereum.CallMsg, *big.Int) ([]byte, error) {
+		return common.RightPadBytes([]byte{0xde, 0xad, 0xbe, 0xef}, 32), nil
+	}
+	reverting := func(ethereum.CallMsg, *big.Int) ([]byte, error) {
+		return nil, &revertError{}
+	}
+
+	type Case struct {
+		Description string
+		Policy      DelegatedEOAPolicy
+		Call        func(msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+		Signature   []byte
+		Valid       bool
+		Status      ValidationStatus
+		Method      VerificationMethod
+	}
+
+	tests := []Case{
+		{"1271 first, delegate without isValidSignature", DelegatedEOAPolicyERC1271First, reverting, sign(key), true, ValidationStatusValid, VerificationMethodECRecover},
+		{"1271 first, delegate accepts", DelegatedEOAPolicyERC1271First, accepting, []byte{0x01}, true, ValidationStatusValid, VerificationMethodERC1271},
+		{"1271 first, delegate rejects", DelegatedEOAPolicyERC1271First, rejecting, sign(key), false, ValidationStatusInvalidMagicValue, VerificationMethodERC1271},
+		{"Either, other signer", DelegatedEOAPolicyEither, accepting, sign(otherKey), true, ValidationStatusValid, VerificationMethodERC1271},
+		{"Either, other signer, delegate rejects", DelegatedEOAPolicyEither, rejecting, sign(otherKey), false, ValidationStatusInvalidMagicValue, VerificationMethodERC1271},
+		{"Either, not ECDSA signature", DelegatedEOAPolicyEither, accepting, []byte{0x01}, true, ValidationStatusValid, VerificationMethodERC1271},
+		{"Either, delegate rejects", DelegatedEOAPolicyEither, rejecting, sign(key), true, ValidationStatusValid, VerificationMethodECRecover},
+	}
+
+	for i, test := range tests {
+		client := &mockCaller{
+			code: map[common.Address][]byte{account: append(common.CopyBytes(DelegationPrefix), delegate.Bytes()...)},
+			call: test.Call,
+		}
+
+		res, err := NewValidator(client).WithDelegatedEOAPolicy(test.Policy).ValidateHashDetailedBytes(ctx, hash, account, test.Signature)
+		if err != nil {
+			t.Errorf("%d (%s): expected err to be nil, got: %s", i, test.Description, err)
+			continue
+		}
+
+		if res.Valid != test.Valid || res.Status != test.Status || res.Method != test.Method {
+			t.Errorf("%d (%s): expected result to be %t (%s, %s), got: %t (%s, %s)", i, test.Description, test.Valid, test.Status, test.Method, res.Valid, res.Status, res.Method)
+		}
+
+		if res.AccountKind != AccountKindDelegatedEOA || res.Delegate != delegate {
+			t.Errorf("%d (%s): expected account to be delegated to %s, got: %s (%s)", i, test.Description, delegate.Hex(), res.AccountKind, res.Delegate.Hex())
+		}
+	}
+}
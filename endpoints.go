@@ -0,0 +1,277 @@
+package erc1271
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/holyheld/gaelogrus"
+)
+
+// EndpointStrategy tells how MultiEndpointCaller distributes the requests between the endpoints
+type EndpointStrategy int
+
+const (
+	// StrategyFailover sends the request to the first healthy endpoint, the next ones are tried on failure
+	StrategyFailover EndpointStrategy = iota
+	// StrategyRandom sends the request to a random healthy endpoint, the other ones are tried on failure
+	StrategyRandom
+	// StrategyQuorum sends the request to every endpoint and returns the response only if enough of them agree
+	StrategyQuorum
+)
+
+// Default backoff of the failed endpoint
+const (
+	DefaultEndpointBackoff    = time.Second
+	DefaultEndpointMaxBackoff = time.Minute
+)
+
+// ErrQuorumNotReached is returned by MultiEndpointCaller in quorum mode when not enough endpoints agree on the response
+var ErrQuorumNotReached = errors.New("quorum not reached")
+
+// MultiEndpointCaller is bind.ContractCaller spreading the requests between several endpoints
+//
+// Endpoints failing with transport or node errors are marked unhealthy and skipped for exponentially growing backoff
+// (unless all endpoints are unhealthy), contract execution failures (reverts) are responses rather than failures. In
+// quorum mode both CodeAt and CallContract responses must be agreed on, so a single lying endpoint can not change the
+// verdict
+type MultiEndpointCaller struct {
+	endpoints  []*endpointState
+	strategy   EndpointStrategy
+	quorum     int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	now        func() time.Time
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// endpointState is the caller with its health state
+type endpointState struct {
+	caller         bind.ContractCaller
+	failures       int
+	unhealthyUntil time.Time
+}
+
+// NewMultiEndpointCaller creates a new MultiEndpointCaller instance, quorum defaults to the majority of the endpoints
+func NewMultiEndpointCaller(strategy EndpointStrategy, callers ...bind.ContractCaller) *MultiEndpointCaller {
+	endpoints := make([]*endpointState, len(callers))
+	for i, caller := range callers {
+		endpoints[i] = &endpointState{caller: caller}
+	}
+
+	return &MultiEndpointCaller{
+		endpoints:  endpoints,
+		strategy:   strategy,
+		quorum:     len(callers)/2 + 1,
+		backoff:    DefaultEndpointBackoff,
+		maxBackoff: DefaultEndpointMaxBackoff,
+		now:        time.Now,
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// WithQuorum sets the number of endpoints that must agree on the response in quorum mode
+func (c *MultiEndpointCaller) WithQuorum(quorum int) *MultiEndpointCaller {
+	c.quorum = quorum
+	return c
+}
+
+// WithBackoff sets initial and maximal time the failed endpoint is skipped for, backoff doubles on every consecutive
+// failure
+func (c *MultiEndpointCaller) WithBackoff(backoff time.Duration, maxBackoff time.Duration) *MultiEndpointCaller {
+	c.backoff = backoff
+	c.maxBackoff = maxBackoff
+	return c
+}
+
+// CodeAt implements bind.ContractCaller
+func (c *MultiEndpointCaller) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return c.do(ctx, "eth_getCode", func(ctx context.Context, caller bind.ContractCaller) ([]byte, error) {
+		return caller.CodeAt(ctx, contract, blockNumber)
+	})
+}
+
+// CallContract implements bind.ContractCaller
+func (c *MultiEndpointCaller) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return c.do(ctx, "eth_call", func(ctx context.Context, caller bind.ContractCaller) ([]byte, error) {
+		return caller.CallContract(ctx, msg, blockNumber)
+	})
+}
+
+// endpointRequest performs the request to the endpoint
+type endpointRequest func(ctx context.Context, caller bind.ContractCaller) ([]byte, error)
+
+// do performs the request according to the strategy
+func (c *MultiEndpointCaller) do(ctx context.Context, method string, request endpointRequest) ([]byte, error) {
+	if len(c.endpoints) == 0 {
+		return nil, errors.New("no endpoints configured")
+	}
+
+	if c.strategy == StrategyQuorum {
+		return c.doQuorum(ctx, method, request)
+	}
+
+	var lastErr error
+	for _, endpoint := range c.order() {
+		out, err := request(ctx, endpoint.caller)
+		if err == nil || IsExecutionError(err) {
+			c.markHealthy(endpoint)
+			return out, err
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		c.markFailed(ctx, endpoint, method, err)
+		lastErr = err
+	}
+
+	return nil, fmt.Errorf("all endpoints failed: %w", lastErr)
+}
+
+// quorumResponse is the response of the endpoint in quorum mode
+type quorumResponse struct {
+	endpoint *endpointState
+	out      []byte
+	err      error
+}
+
+// doQuorum sends the request to every endpoint concurrently and returns the response agreed on by the quorum as soon
+// as no other response can reach the quorum (or fails as soon as none can), the requests still running are cancelled
+func (c *MultiEndpointCaller) doQuorum(ctx context.Context, method string, request endpointRequest) ([]byte, error) {
+	requestCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// buffered, so the requests finishing after the decision do not block
+	responses := make(chan quorumResponse, len(c.endpoints))
+	for _, endpoint := range c.endpoints {
+		go func(endpoint *endpointState) {
+			out, err := request(requestCtx, endpoint.caller)
+			responses <- quorumResponse{endpoint: endpoint, out: out, err: err}
+		}(endpoint)
+	}
+
+	agreed := make(map[string]quorumResponse)
+	votes := make(map[string]int, len(c.endpoints))
+	for remaining := len(c.endpoints); remaining > 0 && !quorumDecided(votes, agreed, c.quorum, remaining); remaining-- {
+		var response quorumResponse
+		select {
+		case response = <-responses:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		if response.err != nil && !IsExecutionError(response.err) {
+			c.markFailed(ctx, response.endpoint, method, response.err)
+			continue
+		}
+		c.markHealthy(response.endpoint)
+
+		key := hexutil.Encode(response.out)
+		if response.err != nil {
+			key = "revert:" + hexutil.Encode(RevertData(response.err))
+		}
+
+		votes[key]++
+		if votes[key] == c.quorum {
+			agreed[key] = response
+		}
+	}
+
+	if len(agreed) != 1 {
+		return nil, fmt.Errorf("%w: %d of %d endpoints required to agree on %s response, votes: %v", ErrQuorumNotReached, c.quorum, len(c.endpoints), method, votes)
+	}
+
+	for _, response := range agreed {
+		return response.out, response.err
+	}
+	return nil, nil
+}
+
+// quorumDecided tells if the outcome is known before the remaining responses arrive: a single response reached the
+// quorum and no other one can, several responses reached it, or none can
+func quorumDecided(votes map[string]int, agreed map[string]quorumResponse, quorum int, remaining int) bool {
+	if len(agreed) > 1 {
+		return true
+	}
+
+	// the remaining responses may all vote for the same (possibly not seen yet) response
+	if remaining >= quorum {
+		return false
+	}
+	for key, count := range votes {
+		if _, ok := agreed[key]; !ok && count+remaining >= quorum {
+			return false
+		}
+	}
+
+	return true
+}
+
+// order returns the endpoints in the order to be tried, healthy ones first
+func (c *MultiEndpointCaller) order() []*endpointState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	endpoints := make([]*endpointState, len(c.endpoints))
+	copy(endpoints, c.endpoints)
+	if c.strategy == StrategyRandom {
+		c.rand.Shuffle(len(endpoints), func(i, j int) { endpoints[i], endpoints[j] = endpoints[j], endpoints[i] })
+	}
+
+	now := c.now()
+	healthy := make([]*endpointState, 0, len(endpoints))
+	var unhealthy []*endpointState
+	for _, endpoint := range endpoints {
+		if now.Before(endpoint.unhealthyUntil) {
+			unhealthy = append(unhealthy, endpoint)
+			continue
+		}
+		healthy = append(healthy, endpoint)
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+// markHealthy resets the failures of the endpoint
+func (c *MultiEndpointCaller) markHealthy(endpoint *endpointState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	endpoint.failures = 0
+	endpoint.unhealthyUntil = time.Time{}
+}
+
+// markFailed records the failure of the endpoint and backs it off
+func (c *MultiEndpointCaller) markFailed(ctx context.Context, endpoint *endpointState, method string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	backoff := c.backoff
+	for i := 0; i < endpoint.failures && backoff < c.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > c.maxBackoff {
+		backoff = c.maxBackoff
+	}
+
+	endpoint.failures++
+	endpoint.unhealthyUntil = c.now().Add(backoff)
+	gaelogrus.GetLogger(ctx).WithError(err).WithFields(map[string]interface{}{
+		"method":   method,
+		"failures": endpoint.failures,
+		"backoff":  backoff.String(),
+	}).Warn("endpoint failed")
+}
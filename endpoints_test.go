@@ -0,0 +1,165 @@
+package erc1271
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// mockEndpoint is bind.ContractCaller answering every call with the same magic value (or failing with err), hanging
+// endpoint does not answer until the context is done
+type mockEndpoint struct {
+	magicValue []byte
+	err        error
+	hang       bool
+	calls      int32
+}
+
+func (m *mockEndpoint) CodeAt(ctx context.Context, _ common.Address, _ *big.Int) ([]byte, error) {
+	atomic.AddInt32(&m.calls, 1)
+	if m.hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	return []byte{0x60, 0x80}, nil
+}
+
+func (m *mockEndpoint) CallContract(ctx context.Context, _ ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	atomic.AddInt32(&m.calls, 1)
+	if m.hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	return common.RightPadBytes(m.magicValue, 32), nil
+}
+
+func TestMultiEndpointCaller(t *testing.T) {
+	ctx := context.Background()
+	hash := crypto.Keccak256Hash([]byte("Hello go test!"))
+	wallet := common.HexToAddress("0x607377F587B1BDc68Bec3E19316D56bA8929d5eB")
+	invalidMagicValue := []byte{0xff, 0xff, 0xff, 0xff}
+	unavailable := errors.New("dial tcp: connection refused")
+
+	type Case struct {
+		Description string
+		Strategy    EndpointStrategy
+		Endpoints   []*mockEndpoint
+		Valid       bool
+		Err         error
+	}
+
+	tests := []Case{
+		{
+			Description: "Failover to the second endpoint",
+			Strategy:    StrategyFailover,
+			Endpoints:   []*mockEndpoint{{err: unavailable}, {magicValue: ValidSignature}},
+			Valid:       true,
+		},
+		{
+			Description: "All endpoints failed",
+			Strategy:    StrategyFailover,
+			Endpoints:   []*mockEndpoint{{err: unavailable}, {err: unavailable}},
+			Err:         ErrRPCUnavailable,
+		},
+		{
+			Description: "Random endpoint",
+			Strategy:    StrategyRandom,
+			Endpoints:   []*mockEndpoint{{magicValue: ValidSignature}, {err: unavailable}, {magicValue: ValidSignature}},
+			Valid:       true,
+		},
+		{
+			Description: "Quorum reached despite lying endpoint",
+			Strategy:    StrategyQuorum,
+			Endpoints:   []*mockEndpoint{{magicValue: invalidMagicValue}, {magicValue: ValidSignature}, {magicValue: ValidSignature}},
+			Valid:       true,
+		},
+		{
+			Description: "Quorum not reached",
+			Strategy:    StrategyQuorum,
+			Endpoints:   []*mockEndpoint{{magicValue: invalidMagicValue}, {magicValue: ValidSignature}, {err: unavailable}},
+			Err:         ErrQuorumNotReached,
+		},
+		{
+			Description: "Quorum reached despite hanging endpoint",
+			Strategy:    StrategyQuorum,
+			Endpoints:   []*mockEndpoint{{hang: true}, {magicValue: ValidSignature}, {magicValue: ValidSignature}},
+			Valid:       true,
+		},
+		{
+			Description: "Quorum can not be reached despite hanging endpoint",
+			Strategy:    StrategyQuorum,
+			Endpoints:   []*mockEndpoint{{err: unavailable}, {hang: true}, {err: unavailable}},
+			Err:         ErrQuorumNotReached,
+		},
+	}
+
+	for i, test := range tests {
+		callers := make([]bind.ContractCaller, len(test.Endpoints))
+		for j, endpoint := range test.Endpoints {
+			callers[j] = endpoint
+		}
+
+		// hanging endpoints must not stall the validation until the deadline
+		callCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		validator := NewValidator(NewMultiEndpointCaller(test.Strategy, callers...))
+		valid, err := validator.ValidateHashBytes(callCtx, hash, wallet, []byte{0x01})
+		cancel()
+		if !errors.Is(err, test.Err) {
+			t.Errorf("%d (%s): expected err to be %v, got: %v", i, test.Description, test.Err, err)
+			continue
+		}
+
+		if valid != test.Valid {
+			t.Errorf("%d (%s): expected result to be %t, got: %t", i, test.Description, test.Valid, valid)
+		}
+	}
+}
+
+func TestMultiEndpointCallerBackoff(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+
+	failing := &mockEndpoint{err: errors.New("dial tcp: connection refused")}
+	healthy := &mockEndpoint{magicValue: ValidSignature}
+	caller := NewMultiEndpointCaller(StrategyFailover, failing, healthy).WithBackoff(time.Second, 4*time.Second)
+	caller.now = func() time.Time { return now }
+
+	type Case struct {
+		Description   string
+		Elapsed       time.Duration
+		ExpectedCalls int32
+	}
+
+	tests := []Case{
+		{"Failing endpoint is tried first", 0, 1},
+		{"Failing endpoint is skipped during backoff", 500 * time.Millisecond, 1},
+		{"Failing endpoint is retried after backoff", time.Second, 2},
+		{"Backoff doubles on consecutive failure", 1500 * time.Millisecond, 2},
+		{"Failing endpoint is retried after doubled backoff", 500 * time.Millisecond, 3},
+	}
+
+	for i, test := range tests {
+		now = now.Add(test.Elapsed)
+		if _, err := caller.CodeAt(ctx, common.Address{}, nil); err != nil {
+			t.Fatalf("%d (%s): expected err to be nil, got: %s", i, test.Description, err)
+		}
+
+		if calls := atomic.LoadInt32(&failing.calls); calls != test.ExpectedCalls {
+			t.Errorf("%d (%s): expected failing endpoint to be called %d times, got: %d", i, test.Description, test.ExpectedCalls, calls)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package erc1271
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/holyheld/gaelogrus"
+)
+
+// ERC165Policy tells how contracts not declaring ERC-1271 support with ERC-165 are treated
+type ERC165Policy int
+
+const (
+	// ERC165PolicyDisabled disables ERC-165 check, isValidSignature is called on every contract
+	ERC165PolicyDisabled ERC165Policy = iota
+	// ERC165PolicyReject rejects signatures of contracts not supporting the interface without isValidSignature call
+	ERC165PolicyReject
+	// ERC165PolicyAttempt records the check outcome in the result and calls isValidSignature anyway
+	ERC165PolicyAttempt
+)
+
+const (
+	// DefaultERC165CacheSize is the number of code hashes (or addresses) ERC-165 check outcome is cached for
+	DefaultERC165CacheSize = 4096
+	// DefaultERC165CacheTTL is the time ERC-165 check outcome is cached for, proxies keep the address across
+	// implementation upgrades, so the outcome is not cached forever
+	DefaultERC165CacheTTL = time.Hour
+)
+
+// erc165CacheKey identifies ERC-165 check outcome, the outcome depends on legacy interface being accepted
+//
+// The outcome is keyed by code hash, unless the code may delegate to the implementation kept in the storage: proxies
+// sharing the code may delegate to different implementations, so their outcome is keyed by address
+type erc165CacheKey struct {
+	codeHash common.Hash
+	address  common.Address
+	legacy   bool
+}
+
+var (
+	// ERC1271InterfaceID is ERC-165 interface ID of isValidSignature(bytes32,bytes)
+	ERC1271InterfaceID = [4]byte{0x16, 0x26, 0xba, 0x7e}
+	// LegacyERC1271InterfaceID is ERC-165 interface ID of legacy isValidSignature(bytes,bytes)
+	LegacyERC1271InterfaceID = [4]byte{0x20, 0xc1, 0x3b, 0x0b}
+
+	// erc165InterfaceID is ERC-165 interface ID of supportsInterface(bytes4)
+	erc165InterfaceID = [4]byte{0x01, 0xff, 0xc9, 0xa7}
+	// erc165InvalidInterfaceID must not be supported by ERC-165 compliant contracts
+	erc165InvalidInterfaceID = [4]byte{0xff, 0xff, 0xff, 0xff}
+)
+
+// erc165GasLimit is the gas supportsInterface calls are limited to by ERC-165 (intrinsic gas of eth_call is added on
+// top of it)
+const erc165GasLimit = 30_000
+
+// supportsInterface performs ERC-165 detection: the contract must support ERC-165 itself, must not support
+// 0xffffffff and must support any of the interfaces, reverts and malformed return values mean the interface is not
+// supported
+//
+// https://eips.ethereum.org/EIPS/eip-165
+func (v *Validator) supportsInterface(ctx context.Context, address common.Address, blockNumber *big.Int, interfaceIDs ...[4]byte) (bool, error) {
+	for _, check := range []struct {
+		interfaceID [4]byte
+		expected    bool
+	}{
+		{erc165InterfaceID, true},
+		{erc165InvalidInterfaceID, false},
+	} {
+		supported, err := v.callSupportsInterface(ctx, address, check.interfaceID, blockNumber)
+		if err != nil || supported != check.expected {
+			return false, err
+		}
+	}
+
+	for _, interfaceID := range interfaceIDs {
+		supported, err := v.callSupportsInterface(ctx, address, interfaceID, blockNumber)
+		if err != nil || supported {
+			return supported, err
+		}
+	}
+
+	return false, nil
+}
+
+// callSupportsInterface calls supportsInterface(bytes4), only ABI encoded true is treated as supported
+func (v *Validator) callSupportsInterface(ctx context.Context, address common.Address, interfaceID [4]byte, blockNumber *big.Int) (bool, error) {
+	callData := append([]byte{0x01, 0xff, 0xc9, 0xa7}, common.RightPadBytes(interfaceID[:], 32)...)
+	out, err := v.callContract(ctx, ethereum.CallMsg{To: &address, Gas: callGas(erc165GasLimit, callData), Data: callData}, blockNumber)
+	if IsExecutionError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, &RPCError{Method: "eth_call", Err: err}
+	}
+
+	return len(out) == 32 && new(big.Int).SetBytes(out).Cmp(common.Big1) == 0, nil
+}
+
+// WithERC165Check enables ERC-165 supportsInterface(0x1626ba7e) check before isValidSignature call (legacy 0x20c13b0b
+// is accepted as well if legacy fallback is enabled), outcome is cached per code hash of validator address (per address
+// for proxies and if the code is not known, i.e. answered from the code cache)
+//
+// Note that many wallets do not implement ERC-165, so ERC165PolicyReject should only be used if the expected wallets do
+func (v *Validator) WithERC165Check(policy ERC165Policy) *Validator {
+	v.erc165Policy = policy
+	if v.erc165Cache == nil {
+		v.erc165Cache = newLRUCache(DefaultERC165CacheSize)
+	}
+	return v
+}
+
+// checkInterface performs ERC-165 check of validator address recording it in the result, returns false if
+// isValidSignature should not be called
+func (v *Validator) checkInterface(ctx context.Context, res *ValidationResult) (bool, error) {
+	if v.erc165Policy == ERC165PolicyDisabled {
+		return true, nil
+	}
+
+	supported, err := v.supportsERC1271(ctx, res.ValidatorAddress, res.erc165CodeHash, res.BlockNumber)
+	if err != nil {
+		gaelogrus.GetLogger(ctx).WithField("address", res.ValidatorAddress).WithError(err).Warn("failed to check erc165 interface")
+		return false, err
+	}
+
+	res.ERC165CheckPerformed = true
+	res.SupportsERC1271 = supported
+	if supported || v.erc165Policy == ERC165PolicyAttempt {
+		return true, nil
+	}
+
+	res.Method = VerificationMethodERC1271
+	res.Status = ValidationStatusInterfaceNotSupported
+	return false, nil
+}
+
+// supportsERC1271 tells if the contract declares ERC-1271 support, outcome at the latest (not pinned) state is cached
+// per code hash, or per address if the code hash is zero
+func (v *Validator) supportsERC1271(ctx context.Context, address common.Address, codeHash common.Hash, blockNumber *big.Int) (bool, error) {
+	key := erc165CacheKey{codeHash: codeHash, legacy: v.legacyFallback}
+	if codeHash == (common.Hash{}) {
+		key.address = address
+	}
+	if blockNumber == nil {
+		if supported, ok := v.erc165Cache.get(key); ok {
+			return supported.(bool), nil
+		}
+	}
+
+	interfaceIDs := [][4]byte{ERC1271InterfaceID}
+	if v.legacyFallback {
+		interfaceIDs = append(interfaceIDs, LegacyERC1271InterfaceID)
+	}
+
+	supported, err := v.supportsInterface(ctx, address, blockNumber, interfaceIDs...)
+	if err != nil {
+		return false, err
+	}
+
+	if blockNumber == nil {
+		v.erc165Cache.set(key, supported, DefaultERC165CacheTTL)
+	}
+	return supported, nil
+}
+
+// erc165CodeHash returns the code hash ERC-165 check outcome can be shared by, zero if the outcome must be cached per
+// address: the code may delegate to the implementation kept in the storage (any DELEGATECALL or CALLCODE but EIP-1167
+// clone, whose implementation is part of the code)
+func erc165CodeHash(code []byte) common.Hash {
+	if len(code) == 0 {
+		return common.Hash{}
+	}
+
+	if _, ok := parseEIP1167(code); !ok && hasDelegateCall(code) {
+		return common.Hash{}
+	}
+
+	return crypto.Keccak256Hash(code)
+}
+
+// hasDelegateCall tells if the code contains DELEGATECALL or CALLCODE instruction, push data is skipped
+func hasDelegateCall(code []byte) bool {
+	for i := 0; i < len(code); i++ {
+		op := vm.OpCode(code[i])
+		if op == vm.DELEGATECALL || op == vm.CALLCODE {
+			return true
+		}
+		if op >= vm.PUSH1 && op <= vm.PUSH32 {
+			i += int(op-vm.PUSH1) + 1
+		}
+	}
+
+	return false
+}
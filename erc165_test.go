@@ -0,0 +1,194 @@
+package erc1271
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestERC165Check(t *testing.T) {
+	ctx := context.Background()
+	hash := crypto.Keccak256Hash([]byte("Hello go test!"))
+	token := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	wallet := common.HexToAddress("0x0000000000000000000000000000000000000002")
+	legacyWallet := common.HexToAddress("0x0000000000000000000000000000000000000003")
+	proxyWallet := common.HexToAddress("0x0000000000000000000000000000000000000004")
+
+	// every contract returns the magic value (the token does it by its fallback function), wallets declare the
+	// interfaces with ERC-165, the proxied wallet needs 20000 gas to answer supportsInterface (the node charges
+	// intrinsic gas as well)
+	interfaces := map[common.Address][][4]byte{
+		wallet:       {erc165InterfaceID, ERC1271InterfaceID},
+		legacyWallet: {erc165InterfaceID, LegacyERC1271InterfaceID},
+		proxyWallet:  {erc165InterfaceID, ERC1271InterfaceID},
+	}
+	client := &mockCaller{
+		code: map[common.Address][]byte{token: {0x60, 0x01}, wallet: {0x60, 0x02}, legacyWallet: {0x60, 0x03}, proxyWallet: {0x60, 0x04}},
+		call: func(msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+			if bytes.Equal(msg.Data[:4], erc165InterfaceID[:]) {
+				if msg.Gas < intrinsicGas(msg.Data) || msg.Gas-intrinsicGas(msg.Data) > erc165GasLimit {
+					return nil, errors.New("unexpected supportsInterface gas")
+				}
+				if *msg.To == proxyWallet && msg.Gas-intrinsicGas(msg.Data) < 20_000 {
+					return nil, errors.New("out of gas")
+				}
+
+				if _, ok := interfaces[*msg.To]; !ok {
+					return common.RightPadBytes(ValidSignature, 32), nil
+				}
+
+				out := make([]byte, 32)
+				for _, interfaceID := range interfaces[*msg.To] {
+					if bytes.Equal(msg.Data[4:8], interfaceID[:]) {
+						out[31] = 1
+					}
+				}
+				return out, nil
+			}
+
+			if bytes.Equal(msg.Data[:4], LegacyValidSignature) {
+				return common.RightPadBytes(LegacyValidSignature, 32), nil
+			}
+			return common.RightPadBytes(ValidSignature, 32), nil
+		},
+	}
+
+	type Case struct {
+		Description string
+		Validator   *Validator
+		Address     common.Address
+		Valid       bool
+		Status      ValidationStatus
+		Supported   bool
+		Err         error
+	}
+
+	tests := []Case{
+		{"Disabled", NewValidator(client), token, true, ValidationStatusValid, false, nil},
+		{"Reject, not supported", NewValidator(client).WithERC165Check(ERC165PolicyReject), token, false, ValidationStatusInterfaceNotSupported, false, nil},
+		{"Reject, supported", NewValidator(client).WithERC165Check(ERC165PolicyReject), wallet, true, ValidationStatusValid, true, nil},
+		{"Reject, proxied wallet", NewValidator(client).WithERC165Check(ERC165PolicyReject), proxyWallet, true, ValidationStatusValid, true, nil},
+		{"Attempt, not supported", NewValidator(client).WithERC165Check(ERC165PolicyAttempt), token, true, ValidationStatusValid, false, nil},
+		{"Reject, legacy interface", NewValidator(client).WithERC165Check(ERC165PolicyReject), legacyWallet, false, ValidationStatusInterfaceNotSupported, false, nil},
+		{"Reject, legacy interface with legacy fallback", NewValidator(client).WithERC165Check(ERC165PolicyReject).WithLegacyFallback(true), legacyWallet, true, ValidationStatusValid, true, nil},
+		{"Strict mode", NewValidator(client).WithERC165Check(ERC165PolicyReject).WithStrictMode(true), token, false, ValidationStatusInterfaceNotSupported, false, ErrInterfaceNotSupported},
+	}
+
+	for i, test := range tests {
+		res, err := test.Validator.ValidateHashDetailedBytes(ctx, hash, test.Address, []byte{0x01})
+		if !errors.Is(err, test.Err) {
+			t.Errorf("%d (%s): expected err to be %v, got: %v", i, test.Description, test.Err, err)
+			continue
+		}
+
+		if res.Valid != test.Valid || res.Status != test.Status || res.SupportsERC1271 != test.Supported {
+			t.Errorf("%d (%s): expected result to be %t (%s, supported: %t), got: %t (%s, supported: %t)", i, test.Description, test.Valid, test.Status, test.Supported, res.Valid, res.Status, res.SupportsERC1271)
+		}
+	}
+}
+
+func TestERC165CheckCache(t *testing.T) {
+	ctx := context.Background()
+	hash := crypto.Keccak256Hash([]byte("Hello go test!"))
+	implementation := common.HexToAddress("0x0000000000000000000000000000000000001000")
+	// proxies share the code, but only the first one delegates to the implementation supporting ERC-165
+	proxies := []common.Address{
+		common.HexToAddress("0x0000000000000000000000000000000000000001"),
+		common.HexToAddress("0x0000000000000000000000000000000000000002"),
+	}
+	// clones of the same implementation share the outcome
+	clones := []common.Address{
+		common.HexToAddress("0x0000000000000000000000000000000000000003"),
+		common.HexToAddress("0x0000000000000000000000000000000000000004"),
+	}
+
+	// PUSH1 0, DELEGATECALL
+	proxyCode := []byte{0x60, 0x00, 0xf4}
+	cloneCode := append(append(common.CopyBytes(eip1167Prefix), implementation.Bytes()...), eip1167Suffix...)
+
+	calls := 0
+	client := &mockCaller{
+		code: map[common.Address][]byte{proxies[0]: proxyCode, proxies[1]: proxyCode, clones[0]: cloneCode, clones[1]: cloneCode},
+		call: func(msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+			if !bytes.Equal(msg.Data[:4], erc165InterfaceID[:]) {
+				return common.RightPadBytes(ValidSignature, 32), nil
+			}
+
+			calls++
+			if *msg.To != proxies[0] {
+				return nil, &revertError{}
+			}
+			out := make([]byte, 32)
+			if !bytes.Equal(msg.Data[4:8], erc165InvalidInterfaceID[:]) {
+				out[31] = 1
+			}
+			return out, nil
+		},
+	}
+
+	addresses := append(append([]common.Address{}, proxies...), clones...)
+	validator := NewValidator(client).WithERC165Check(ERC165PolicyReject)
+	for _, address := range addresses {
+		if _, err := validator.AtBlockNumber(big.NewInt(1)).ValidateHashDetailedBytes(ctx, hash, address, []byte{0x01}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 6 {
+		t.Errorf("expected pinned checks not to be cached, got %d calls", calls)
+	}
+
+	// the first round fills the cache (the second clone is answered by the first one), the second one is answered
+	// from it
+	expected := []ValidationStatus{
+		ValidationStatusValid,
+		ValidationStatusInterfaceNotSupported,
+		ValidationStatusInterfaceNotSupported,
+		ValidationStatusInterfaceNotSupported,
+	}
+	for round, expectedCalls := range []int{5, 0} {
+		calls = 0
+		for i, address := range addresses {
+			res, err := validator.ValidateHashDetailedBytes(ctx, hash, address, []byte{0x01})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Status != expected[i] {
+				t.Errorf("%d (%s): expected status to be %s, got: %s", i, address.Hex(), expected[i], res.Status)
+			}
+		}
+		if calls != expectedCalls {
+			t.Errorf("round %d: expected the check to be cached per code hash of clones and per address of proxies, got %d calls", round, calls)
+		}
+	}
+}
+
+func TestERC165CodeHash(t *testing.T) {
+	clone := append(append(common.CopyBytes(eip1167Prefix), common.HexToAddress("0x01").Bytes()...), eip1167Suffix...)
+
+	type Case struct {
+		Description string
+		Code        []byte
+		Shared      bool
+	}
+
+	tests := []Case{
+		{"No code", nil, false},
+		{"Plain contract", []byte{0x60, 0x00, 0x54}, true},
+		{"DELEGATECALL in push data", []byte{0x61, 0xf4, 0xf2, 0x50}, true},
+		{"DELEGATECALL", []byte{0x60, 0x00, 0xf4}, false},
+		{"CALLCODE", []byte{0x60, 0x00, 0xf2}, false},
+		{"EIP-1167 clone", clone, true},
+	}
+
+	for i, test := range tests {
+		if shared := erc165CodeHash(test.Code) != (common.Hash{}); shared != test.Shared {
+			t.Errorf("%d (%s): expected outcome to be shared by code hash: %t, got: %t", i, test.Description, test.Shared, shared)
+		}
+	}
+}
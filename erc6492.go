@@ -0,0 +1,226 @@
+package erc1271
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/holyheld/gaelogrus"
+)
+
+// erc6492Arguments describes abi.encode(address factory, bytes factoryCalldata, bytes innerSig) wrapper
+var erc6492Arguments = mustArguments("address", "bytes", "bytes")
+
+// ERC6492Signature is a decoded ERC6492 wrapped signature of counterfactual wallet
+//
+// https://eips.ethereum.org/EIPS/eip-6492
+type ERC6492Signature struct {
+	Factory         common.Address
+	FactoryCalldata []byte
+	Signature       []byte
+}
+
+// IsERC6492Signature checks if signature ends with ERC6492 magic suffix
+func IsERC6492Signature(signature []byte) bool {
+	return len(signature) > len(ERC6492MagicSuffix) && bytes.HasSuffix(signature, ERC6492MagicSuffix)
+}
+
+// ParseERC6492Signature decodes abi.encode(factory, factoryCalldata, innerSig) ‖ magicSuffix signature
+func ParseERC6492Signature(signature []byte) (*ERC6492Signature, error) {
+	if !IsERC6492Signature(signature) {
+		return nil, errors.New("signature is missing ERC6492 magic suffix")
+	}
+
+	values, err := erc6492Arguments.UnpackValues(signature[:len(signature)-len(ERC6492MagicSuffix)])
+	if err != nil {
+		return nil, err
+	}
+
+	return &ERC6492Signature{
+		Factory:         values[0].(common.Address),
+		FactoryCalldata: values[1].([]byte),
+		Signature:       values[2].([]byte),
+	}, nil
+}
+
+// Bytes encodes the signature back into abi.encode(factory, factoryCalldata, innerSig) ‖ magicSuffix form
+func (s *ERC6492Signature) Bytes() ([]byte, error) {
+	packed, err := erc6492Arguments.Pack(s.Factory, s.FactoryCalldata, s.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(packed, ERC6492MagicSuffix...), nil
+}
+
+// validateERC6492 validates ERC6492 wrapped signature
+//
+// If the wallet is already deployed inner signature is validated with plain isValidSignature(bytes32,bytes) call,
+// otherwise factory deployment and isValidSignature call are simulated in a single deployless eth_call
+func (v *Validator) validateERC6492(ctx context.Context, res *ValidationResult, data []byte, signature []byte) error {
+	logger := gaelogrus.GetLogger(ctx).WithField("func", "validateERC6492")
+	res.Method = VerificationMethodERC6492
+	wrapped, err := ParseERC6492Signature(signature)
+	if err != nil {
+		logger.WithError(err).Debug("failed to parse ERC6492 signature")
+		res.Status = ValidationStatusMalformedSignature
+		return nil
+	}
+
+	if !v.skipIsContractCheck {
+		if err := v.checkContract(ctx, res); err != nil {
+			return err
+		}
+
+		if res.IsContract {
+			return v.contractSignature(ctx, res, data, wrapped.Signature)
+		}
+	}
+
+	parsed, err := ContractMetaData.GetAbi()
+	if err != nil {
+		logger.WithError(err).Debug("failed to parse contract abi")
+		return err
+	}
+
+	callData, err := parsed.Pack("isValidSignature", res.Digest, wrapped.Signature)
+	if err != nil {
+		logger.WithError(err).Debug("failed to pack isValidSignature call")
+		return err
+	}
+
+	code, err := erc6492DeploylessCode(wrapped.Factory, wrapped.FactoryCalldata, res.ValidatorAddress, callData, v.sig)
+	if err != nil {
+		logger.WithError(err).Debug("failed to build deployless code")
+		res.Status = ValidationStatusMalformedSignature
+		return nil
+	}
+
+	out, err := v.callContract(ctx, ethereum.CallMsg{From: v.callSender(res.Signer), Data: code}, res.BlockNumber)
+	if err != nil {
+		return v.handleCallError(ctx, res, err)
+	}
+
+	// deployless code only tells if the magic value was returned
+	if len(out) != 1 || out[0] != 1 {
+		res.Status = ValidationStatusInvalidMagicValue
+		return nil
+	}
+
+	res.ReturnValue = common.CopyBytes(v.sig)
+	res.Valid = true
+	res.Status = ValidationStatusValid
+	return nil
+}
+
+// erc6492DeploylessCode builds contract creation code to be executed with eth_call without "to", which
+//
+//  1. calls factory with factoryCalldata (ignoring failures, i.e. when the wallet is already deployed)
+//  2. static calls validator with isValidSignature calldata
+//  3. returns single byte 0x01 if the result is ABI encoded magic value (0x00 otherwise), or reverts with the result
+//     if the call failed
+//
+// The result is compared in the code rather than returned as is, since the returned bytes become the runtime code of
+// the created contract, which EIP-3541 rejects if it starts with 0xef. Both calldata blobs are appended to the code and
+// copied into memory with CODECOPY
+func erc6492DeploylessCode(factory common.Address, factoryCalldata []byte, validator common.Address, callData []byte, magicValue []byte) ([]byte, error) {
+	if len(factoryCalldata) > 0xffff || len(callData) > 0xffff {
+		return nil, fmt.Errorf("calldata is too large: %d, %d", len(factoryCalldata), len(callData))
+	}
+
+	build := func(programLength int) []byte {
+		factoryCalldataOffset := programLength
+		callDataOffset := factoryCalldataOffset + len(factoryCalldata)
+		resultOffset := len(factoryCalldata)
+		if len(callData) > resultOffset {
+			resultOffset = len(callData)
+		}
+
+		var code []byte
+		push1 := func(b byte) { code = append(code, 0x60, b) }
+		push2 := func(n int) { code = append(code, 0x61, byte(n>>8), byte(n)) }
+		push4 := func(b []byte) { code = append(append(code, 0x63), common.RightPadBytes(b, 4)[:4]...) }
+		push20 := func(a common.Address) { code = append(append(code, 0x73), a.Bytes()...) }
+
+		// CODECOPY(0, factoryCalldataOffset, len(factoryCalldata))
+		push2(len(factoryCalldata))
+		push2(factoryCalldataOffset)
+		push1(0)
+		code = append(code, 0x39)
+		// POP(CALL(GAS, factory, 0, 0, len(factoryCalldata), 0, 0))
+		push1(0)
+		push1(0)
+		push2(len(factoryCalldata))
+		push1(0)
+		push1(0)
+		push20(factory)
+		code = append(code, 0x5a, 0xf1, 0x50)
+		// CODECOPY(0, callDataOffset, len(callData))
+		push2(len(callData))
+		push2(callDataOffset)
+		push1(0)
+		code = append(code, 0x39)
+		// STATICCALL(GAS, validator, 0, len(callData), resultOffset, 32)
+		push1(32)
+		push2(resultOffset)
+		push2(len(callData))
+		push1(0)
+		push20(validator)
+		code = append(code, 0x5a, 0xfa)
+		// JUMPI(revert, ISZERO(success))
+		code = append(code, 0x15)
+		push2(programLength - 11)
+		code = append(code, 0x57)
+		// MSTORE8(0, AND(GT(RETURNDATASIZE, 31), EQ(SHR(224, MLOAD(resultOffset)), magicValue)))
+		push2(resultOffset)
+		code = append(code, 0x51)
+		push1(224)
+		code = append(code, 0x1c)
+		push4(magicValue)
+		code = append(code, 0x14)
+		push1(31)
+		code = append(code, 0x3d, 0x11, 0x16)
+		push1(0)
+		code = append(code, 0x53)
+		// RETURN(0, 1)
+		push1(1)
+		push1(0)
+		code = append(code, 0xf3)
+		// revert: JUMPDEST, RETURNDATACOPY(0, 0, RETURNDATASIZE), REVERT(0, RETURNDATASIZE)
+		code = append(code, 0x5b, 0x3d)
+		push1(0)
+		push1(0)
+		code = append(code, 0x3e, 0x3d)
+		push1(0)
+		code = append(code, 0xfd)
+
+		return code
+	}
+
+	program := build(len(build(0)))
+	code := make([]byte, 0, len(program)+len(factoryCalldata)+len(callData))
+	code = append(code, program...)
+	code = append(code, factoryCalldata...)
+	code = append(code, callData...)
+
+	return code, nil
+}
+
+// mustArguments builds abi.Arguments out of the type names, panics on unknown type
+func mustArguments(types ...string) abi.Arguments {
+	arguments := make(abi.Arguments, len(types))
+	for i, name := range types {
+		typ, err := abi.NewType(name, "", nil)
+		if err != nil {
+			panic(err)
+		}
+		arguments[i] = abi.Argument{Type: typ}
+	}
+
+	return arguments
+}
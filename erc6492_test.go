@@ -0,0 +1,108 @@
+package erc1271
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestERC6492DeploylessCode(t *testing.T) {
+	expectedHash := crypto.Keccak256Hash([]byte("Hello go test!"))
+
+	// init code of the wallet returning the value only for expectedHash
+	walletInitCode := func(value []byte) []byte {
+		walletCode := append(append([]byte{0x7f}, expectedHash.Bytes()...), 0x60, 0x04, 0x35, 0x14, 0x60, 0x29, 0x57, 0x00, 0x5b, 0x7f)
+		walletCode = append(walletCode, common.RightPadBytes(value, 32)...)
+		walletCode = append(walletCode, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3)
+		return append([]byte{0x60, byte(len(walletCode)), 0x60, 0x0c, 0x60, 0x00, 0x39, 0x60, byte(len(walletCode)), 0x60, 0x00, 0xf3}, walletCode...)
+	}
+	// factory code creating contract out of calldata
+	factoryCode := []byte{0x36, 0x60, 0x00, 0x60, 0x00, 0x37, 0x36, 0x60, 0x00, 0x60, 0x00, 0xf0, 0x50, 0x00}
+
+	factory := common.HexToAddress("0x00000000000000000000000000000000000fac70")
+	wallet := crypto.CreateAddress(factory, 0)
+
+	type Case struct {
+		Description string
+		Hash        common.Hash
+		ReturnValue []byte
+		Valid       bool
+	}
+
+	tests := []Case{
+		{
+			Description: "Valid counterfactual signature",
+			Hash:        expectedHash,
+			ReturnValue: ValidSignature,
+			Valid:       true,
+		},
+		{
+			Description: "Invalid counterfactual signature",
+			Hash:        crypto.Keccak256Hash([]byte("Hello go test!!")),
+			ReturnValue: ValidSignature,
+			Valid:       false,
+		},
+		{
+			// returned as is it would be rejected as runtime code by EIP-3541
+			Description: "Return value starting with 0xef",
+			Hash:        expectedHash,
+			ReturnValue: []byte{0xef, 0x01, 0x02, 0x03},
+			Valid:       false,
+		},
+	}
+
+	for i, test := range tests {
+		wrapped := &ERC6492Signature{Factory: factory, FactoryCalldata: walletInitCode(test.ReturnValue), Signature: []byte{0x01}}
+		signature, err := wrapped.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !IsERC6492Signature(signature) {
+			t.Fatalf("%d (%s): expected signature to be detected as ERC6492", i, test.Description)
+		}
+
+		parsed, err := ParseERC6492Signature(signature)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsedABI, err := ContractMetaData.GetAbi()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		callData, err := parsedABI.Pack("isValidSignature", test.Hash, parsed.Signature)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code, err := erc6492DeploylessCode(parsed.Factory, parsed.FactoryCalldata, wallet, callData, ValidSignature)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		statedb, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		statedb.SetCode(factory, factoryCode)
+
+		res, _, _, err := runtime.Create(code, &runtime.Config{State: statedb, ChainConfig: params.MainnetChainConfig, BlockNumber: params.MainnetChainConfig.LondonBlock})
+		if err != nil {
+			t.Errorf("%d (%s): expected err to be nil, got: %s", i, test.Description, err)
+			continue
+		}
+
+		valid := bytes.Equal(res, []byte{0x01})
+		if valid != test.Valid {
+			t.Errorf("%d (%s): expected result to be %t, got: %t", i, test.Description, test.Valid, valid)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package erc1271
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"github.com/holyheld/gaelogrus"
+)
+
+// ERC7739DetectionHash is the hash isValidSignature is probed with (along with empty signature) to detect ERC-7739
+// support, supporting accounts return 0x7739 followed by 2 bytes of the version
+//
+// https://eips.ethereum.org/EIPS/eip-7739
+var ERC7739DetectionHash = common.HexToHash("0x7739773977397739773977397739773977397739773977397739773977397739")
+
+// erc7739MagicPrefix is the prefix of the value returned to the detection probe
+var erc7739MagicPrefix = []byte{0x77, 0x39}
+
+var (
+	// personalSignTypeHash is the type hash of ERC-7739 PersonalSign struct
+	personalSignTypeHash = crypto.Keccak256Hash([]byte("PersonalSign(bytes prefixed)"))
+	// eip712DomainFields are EIP712Domain fields in the order of ERC-5267 fields bitmap
+	eip712DomainFields = []string{"string name", "string version", "uint256 chainId", "address verifyingContract", "bytes32 salt"}
+)
+
+// eip5267ABI is ERC-5267 eip712Domain() ABI
+const eip5267ABI = `[{"inputs":[],"name":"eip712Domain","outputs":[{"internalType":"bytes1","name":"fields","type":"bytes1"},{"internalType":"string","name":"name","type":"string"},{"internalType":"string","name":"version","type":"string"},{"internalType":"uint256","name":"chainId","type":"uint256"},{"internalType":"address","name":"verifyingContract","type":"address"},{"internalType":"bytes32","name":"salt","type":"bytes32"},{"internalType":"uint256[]","name":"extensions","type":"uint256[]"}],"stateMutability":"view","type":"function"}]`
+
+// eip5267 is parsed eip5267ABI
+var eip5267 = mustABI(eip5267ABI)
+
+// EIP712Domain is EIP-712 domain of the account as returned by ERC-5267 eip712Domain()
+//
+// https://eips.ethereum.org/EIPS/eip-5267
+type EIP712Domain struct {
+	// Fields is the bitmap of the used fields: 0x01 name, 0x02 version, 0x04 chainId, 0x08 verifyingContract, 0x10 salt
+	Fields            byte
+	Name              string
+	Version           string
+	ChainID           *big.Int
+	VerifyingContract common.Address
+	Salt              common.Hash
+}
+
+// Separator computes EIP-712 domain separator out of the used fields
+func (d *EIP712Domain) Separator() common.Hash {
+	chainID := d.ChainID
+	if chainID == nil {
+		chainID = new(big.Int)
+	}
+
+	values := [][]byte{
+		crypto.Keccak256([]byte(d.Name)),
+		crypto.Keccak256([]byte(d.Version)),
+		common.LeftPadBytes(chainID.Bytes(), 32),
+		common.LeftPadBytes(d.VerifyingContract.Bytes(), 32),
+		d.Salt.Bytes(),
+	}
+
+	var fields []string
+	encoded := [][]byte{nil}
+	for i, field := range eip712DomainFields {
+		if d.Fields&(1<<i) != 0 {
+			fields = append(fields, field)
+			encoded = append(encoded, values[i])
+		}
+	}
+	encoded[0] = crypto.Keccak256([]byte("EIP712Domain(" + strings.Join(fields, ",") + ")"))
+
+	return crypto.Keccak256Hash(encoded...)
+}
+
+// ERC7739PersonalSignHash computes the hash the account owner signs for EIP-191 personal message hash (e.g.
+// accounts.TextHash(message)), the signature is passed to isValidSignature along with the bare personal message hash
+func ERC7739PersonalSignHash(domain *EIP712Domain, hash common.Hash) common.Hash {
+	structHash := crypto.Keccak256(personalSignTypeHash.Bytes(), hash.Bytes())
+	return crypto.Keccak256Hash([]byte{0x19, 0x01}, domain.Separator().Bytes(), structHash)
+}
+
+// ERC7739TypedDataSignHash computes the hash the account owner signs for the typed data, i.e. the typed data contents
+// wrapped into TypedDataSign struct along with the account domain, hashed within the application domain
+func ERC7739TypedDataSignHash(domain *EIP712Domain, typedData apitypes.TypedData) (common.Hash, error) {
+	appDomainSeparator, contentsHash, contentsType, err := erc7739Contents(typedData)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	chainID := domain.ChainID
+	if chainID == nil {
+		chainID = new(big.Int)
+	}
+
+	typeHash := crypto.Keccak256(
+		[]byte("TypedDataSign("+typedData.PrimaryType+" contents,string name,string version,uint256 chainId,address verifyingContract,bytes32 salt)"),
+		contentsType,
+	)
+	structHash := crypto.Keccak256(
+		typeHash,
+		contentsHash,
+		crypto.Keccak256([]byte(domain.Name)),
+		crypto.Keccak256([]byte(domain.Version)),
+		common.LeftPadBytes(chainID.Bytes(), 32),
+		common.LeftPadBytes(domain.VerifyingContract.Bytes(), 32),
+		domain.Salt.Bytes(),
+	)
+
+	return crypto.Keccak256Hash([]byte{0x19, 0x01}, appDomainSeparator, structHash), nil
+}
+
+// ERC7739TypedDataSignature wraps the owner signature over ERC7739TypedDataSignHash into the signature accepted by
+// isValidSignature along with the typed data hash (TypedDataHash), i.e.
+// signature ‖ appDomainSeparator ‖ contentsHash ‖ contentsType ‖ uint16(len(contentsType))
+func ERC7739TypedDataSignature(typedData apitypes.TypedData, signature []byte) ([]byte, error) {
+	appDomainSeparator, contentsHash, contentsType, err := erc7739Contents(typedData)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(contentsType) > 0xffff {
+		return nil, fmt.Errorf("contents type is too long: %d", len(contentsType))
+	}
+
+	length := make([]byte, 2)
+	binary.BigEndian.PutUint16(length, uint16(len(contentsType)))
+
+	return bytes.Join([][]byte{signature, appDomainSeparator, contentsHash, contentsType, length}, nil), nil
+}
+
+// erc7739Contents returns application domain separator, contents hash and encoded contents type of the typed data
+func erc7739Contents(typedData apitypes.TypedData) ([]byte, []byte, []byte, error) {
+	appDomainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	contentsHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return appDomainSeparator, contentsHash, typedData.EncodeType(typedData.PrimaryType), nil
+}
+
+// EIP712Domain calls ERC-5267 eip712Domain() on the account
+func (v *Validator) EIP712Domain(ctx context.Context, address common.Address) (*EIP712Domain, error) {
+	if v.err != nil {
+		return nil, v.err
+	}
+
+	blockNumber, _, err := v.resolveBlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	callData, err := eip5267.Pack("eip712Domain")
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := v.callContract(ctx, ethereum.CallMsg{To: &address, Gas: callGas(v.gasLimit, callData), Data: callData}, blockNumber)
+	if err != nil {
+		if IsExecutionError(err) {
+			return nil, newExecutionRevertedError(err)
+		}
+		return nil, &RPCError{Method: "eth_call", Err: err}
+	}
+
+	values, err := eip5267.Unpack("eip712Domain", out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode eip712Domain: %w", err)
+	}
+
+	fields := values[0].([1]byte)
+	return &EIP712Domain{
+		Fields:            fields[0],
+		Name:              values[1].(string),
+		Version:           values[2].(string),
+		ChainID:           values[3].(*big.Int),
+		VerifyingContract: values[4].(common.Address),
+		Salt:              values[5].([32]byte),
+	}, nil
+}
+
+// SupportsERC7739 probes isValidSignature with ERC7739DetectionHash and empty signature, reverts and other return
+// values mean the account does not support ERC-7739
+func (v *Validator) SupportsERC7739(ctx context.Context, address common.Address) (bool, error) {
+	if v.err != nil {
+		return false, v.err
+	}
+
+	blockNumber, _, err := v.resolveBlock(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	parsed, err := ContractMetaData.GetAbi()
+	if err != nil {
+		return false, err
+	}
+
+	callData, err := parsed.Pack("isValidSignature", ERC7739DetectionHash, []byte{})
+	if err != nil {
+		return false, err
+	}
+
+	out, err := v.callContract(ctx, ethereum.CallMsg{To: &address, Gas: callGas(v.gasLimit, callData), Data: callData}, blockNumber)
+	if err != nil {
+		if IsExecutionError(err) {
+			gaelogrus.GetLogger(ctx).WithField("address", address).WithError(err).Debug("erc7739 probe reverted")
+			return false, nil
+		}
+		return false, &RPCError{Method: "eth_call", Err: err}
+	}
+
+	return len(out) >= 32 && bytes.HasPrefix(out, erc7739MagicPrefix), nil
+}
@@ -0,0 +1,175 @@
+package erc1271
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+func TestERC7739Hashes(t *testing.T) {
+	account := common.HexToAddress("0x607377F587B1BDc68Bec3E19316D56bA8929d5eB")
+	domain := &EIP712Domain{Fields: 0x0f, Name: "Wallet", Version: "1", ChainID: big.NewInt(1), VerifyingContract: account}
+	accountDomain := apitypes.TypedDataDomain{Name: "Wallet", Version: "1", ChainId: math.NewHexOrDecimal256(1), VerifyingContract: account.Hex()}
+	domainTypes := []apitypes.Type{{Name: "name", Type: "string"}, {Name: "version", Type: "string"}, {Name: "chainId", Type: "uint256"}, {Name: "verifyingContract", Type: "address"}}
+
+	mail := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": domainTypes,
+			"Mail":         {{Name: "from", Type: "Person"}, {Name: "contents", Type: "string"}},
+			"Person":       {{Name: "name", Type: "string"}, {Name: "wallet", Type: "address"}},
+		},
+		PrimaryType: "Mail",
+		Domain:      apitypes.TypedDataDomain{Name: "App", Version: "2", ChainId: math.NewHexOrDecimal256(1), VerifyingContract: "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"},
+		Message: apitypes.TypedDataMessage{
+			"from":     map[string]interface{}{"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+			"contents": "Hello, Bob!",
+		},
+	}
+
+	// the same structs encoded by go-ethereum EIP-712 implementation
+	message := []byte("Hello go test!")
+	personalSign := apitypes.TypedData{
+		Types:       apitypes.Types{"EIP712Domain": domainTypes, "PersonalSign": {{Name: "prefixed", Type: "bytes"}}},
+		PrimaryType: "PersonalSign",
+		Domain:      accountDomain,
+		Message:     apitypes.TypedDataMessage{"prefixed": hexutil.Encode(append([]byte("\x19Ethereum Signed Message:\n14"), message...))},
+	}
+	typedDataSign := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": domainTypes,
+			"TypedDataSign": {
+				{Name: "contents", Type: "Mail"}, {Name: "name", Type: "string"}, {Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"}, {Name: "verifyingContract", Type: "address"}, {Name: "salt", Type: "bytes32"},
+			},
+			"Mail":   mail.Types["Mail"],
+			"Person": mail.Types["Person"],
+		},
+		PrimaryType: "TypedDataSign",
+		Domain:      mail.Domain,
+		Message: apitypes.TypedDataMessage{
+			"contents": mail.Message, "name": "Wallet", "version": "1", "chainId": "1",
+			"verifyingContract": account.Hex(), "salt": common.Hash{}.Hex(),
+		},
+	}
+
+	expectedSeparator, err := personalSign.HashStruct("EIP712Domain", accountDomain.Map())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if separator := domain.Separator(); !bytes.Equal(separator.Bytes(), expectedSeparator) {
+		t.Errorf("expected domain separator to be %s, got: %s", hexutil.Encode(expectedSeparator), separator)
+	}
+
+	expectedPersonalSign, err := TypedDataHash(personalSign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash := ERC7739PersonalSignHash(domain, common.BytesToHash(accounts.TextHash(message))); hash != expectedPersonalSign {
+		t.Errorf("expected personal sign hash to be %s, got: %s", expectedPersonalSign, hash)
+	}
+
+	expectedTypedDataSign, err := TypedDataHash(typedDataSign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := ERC7739TypedDataSignHash(domain, mail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != expectedTypedDataSign {
+		t.Errorf("expected typed data sign hash to be %s, got: %s", expectedTypedDataSign, hash)
+	}
+
+	signature, err := ERC7739TypedDataSignature(mail, []byte{0x01, 0x02})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contentsType := mail.EncodeType("Mail")
+	if !bytes.HasPrefix(signature, []byte{0x01, 0x02}) || !bytes.HasSuffix(signature, append(contentsType, 0x00, byte(len(contentsType)))) || len(signature) != 2+64+len(contentsType)+2 {
+		t.Errorf("unexpected wrapped signature: %s", hexutil.Encode(signature))
+	}
+}
+
+func TestSupportsERC7739(t *testing.T) {
+	ctx := context.Background()
+	account := common.HexToAddress("0x607377F587B1BDc68Bec3E19316D56bA8929d5eB")
+
+	type Case struct {
+		Description string
+		Call        func(msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+		Supported   bool
+		Err         error
+	}
+
+	tests := []Case{
+		{
+			Description: "Supported",
+			Call: func(msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+				if !bytes.Equal(msg.Data[4:36], ERC7739DetectionHash.Bytes()) {
+					return nil, &revertError{}
+				}
+				return common.RightPadBytes([]byte{0x77, 0x39, 0x00, 0x01}, 32), nil
+			},
+			Supported: true,
+		},
+		{
+			Description: "Reverted",
+			Call: func(ethereum.CallMsg, *big.Int) ([]byte, error) {
+				return nil, &revertError{}
+			},
+			Supported: false,
+		},
+		{
+			Description: "Magic value",
+			Call: func(ethereum.CallMsg, *big.Int) ([]byte, error) {
+				return common.RightPadBytes(ValidSignature, 32), nil
+			},
+			Supported: false,
+		},
+		{
+			Description: "RPC failure",
+			Call: func(ethereum.CallMsg, *big.Int) ([]byte, error) {
+				return nil, errors.New("connection refused")
+			},
+			Supported: false,
+			Err:       ErrRPCUnavailable,
+		},
+	}
+
+	for i, test := range tests {
+		supported, err := NewValidator(&mockCaller{call: test.Call}).SupportsERC7739(ctx, account)
+		if !errors.Is(err, test.Err) {
+			t.Errorf("%d (%s): expected err to be %v, got: %v", i, test.Description, test.Err, err)
+			continue
+		}
+
+		if supported != test.Supported {
+			t.Errorf("%d (%s): expected result to be %t, got: %t", i, test.Description, test.Supported, supported)
+		}
+	}
+}
+
+func TestEIP712Domain(t *testing.T) {
+	account := common.HexToAddress("0x607377F587B1BDc68Bec3E19316D56bA8929d5eB")
+	client := &mockCaller{call: func(ethereum.CallMsg, *big.Int) ([]byte, error) {
+		return eip5267.Methods["eip712Domain"].Outputs.Pack([1]byte{0x0f}, "Wallet", "1", big.NewInt(1), account, [32]byte{}, []*big.Int{})
+	}}
+
+	domain, err := NewValidator(client).EIP712Domain(context.Background(), account)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if domain.Fields != 0x0f || domain.Name != "Wallet" || domain.Version != "1" || domain.ChainID.Int64() != 1 || domain.VerifyingContract != account {
+		t.Errorf("unexpected domain: %+v", domain)
+	}
+}
@@ -0,0 +1,144 @@
+package erc1271
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	// ErrNotContract is returned in strict mode when validator address has no code
+	ErrNotContract = errors.New("address is not a contract")
+	// ErrExecutionReverted is matched (errors.Is) by ExecutionRevertedError
+	ErrExecutionReverted = errors.New("execution reverted")
+	// ErrInvalidSignatureHex is returned when signature is not a valid hex value
+	ErrInvalidSignatureHex = errors.New("invalid signature hex")
+	// ErrInvalidAddress is returned when address is not a valid hex address
+	ErrInvalidAddress = errors.New("invalid address")
+	// ErrInvalidMagicValue is returned when custom magic value is not a valid 4 bytes hex value
+	ErrInvalidMagicValue = errors.New("invalid magic value")
+	// ErrRPCUnavailable is matched (errors.Is) by RPCError
+	ErrRPCUnavailable = errors.New("rpc unavailable")
+	// ErrUnsupportedChain is returned by MultiChainValidator when the chain is not configured
+	ErrUnsupportedChain = errors.New("unsupported chain")
+	// ErrChainIDMismatch is returned by MultiChainValidator.CheckChainIDs when the client is connected to a different chain
+	ErrChainIDMismatch = errors.New("chain id mismatch")
+	// ErrNotSafe is returned by Validator.SafeInfo when the contract does not behave like a Safe wallet
+	ErrNotSafe = errors.New("address is not a safe")
+	// ErrInterfaceNotSupported is returned in strict mode when validator address does not declare ERC-1271 support
+	// with ERC-165 and ERC165PolicyReject is used
+	ErrInterfaceNotSupported = errors.New("erc1271 interface is not supported")
+)
+
+// executionErrors are the messages of EVM execution failures, reported by the nodes as JSON-RPC errors
+var executionErrors = []string{
+	"revert",
+	"out of gas",
+	"invalid opcode",
+	"invalid jump destination",
+	"stack underflow",
+	"stack limit reached",
+	"write protection",
+	"return data out of bounds",
+	"max call depth exceeded",
+	// the gas limit does not cover the call data, the call is not executed at all
+	"intrinsic gas too low",
+}
+
+// ExecutionRevertedError is returned in strict mode when isValidSignature call reverted
+type ExecutionRevertedError struct {
+	// Reason is the decoded Error(string) revert reason (if any)
+	Reason string
+	// Data is the raw revert data (if provided by the node), i.e. ABI encoded custom error
+	Data []byte
+}
+
+// Error implements error interface
+func (e *ExecutionRevertedError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("%s: %s", ErrExecutionReverted, e.Reason)
+	}
+
+	if len(e.Data) > 0 {
+		return fmt.Sprintf("%s: %s", ErrExecutionReverted, hexutil.Encode(e.Data))
+	}
+
+	return ErrExecutionReverted.Error()
+}
+
+// Is makes ExecutionRevertedError match ErrExecutionReverted
+func (e *ExecutionRevertedError) Is(target error) bool {
+	return target == ErrExecutionReverted
+}
+
+// Selector returns the 4 bytes selector of the custom error (nil if there is no revert data)
+func (e *ExecutionRevertedError) Selector() []byte {
+	if len(e.Data) < 4 {
+		return nil
+	}
+
+	return e.Data[:4]
+}
+
+// RPCError wraps transport and node failures (as opposed to contract execution failures)
+type RPCError struct {
+	// Method is the JSON-RPC method that failed
+	Method string
+	Err    error
+}
+
+// Error implements error interface
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", ErrRPCUnavailable, e.Method, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *RPCError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes RPCError match ErrRPCUnavailable
+func (e *RPCError) Is(target error) bool {
+	return target == ErrRPCUnavailable
+}
+
+// newExecutionRevertedError builds ExecutionRevertedError out of the contract call error
+func newExecutionRevertedError(err error) *ExecutionRevertedError {
+	res := &ExecutionRevertedError{Data: RevertData(err)}
+	if reason, err := abi.UnpackRevert(res.Data); err == nil {
+		res.Reason = reason
+	}
+
+	return res
+}
+
+// IsExecutionError tells if the contract call error is caused by EVM execution failure (revert, out of gas, etc.)
+// rather than by RPC transport or node failure
+func IsExecutionError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) && dataErr.ErrorData() != nil {
+		return true
+	}
+
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == 3 {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, executionError := range executionErrors {
+		if strings.Contains(message, executionError) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,361 @@
+package erc1271
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/holyheld/gaelogrus"
+)
+
+// ProxyKind tells which proxy pattern the contract follows
+type ProxyKind int
+
+const (
+	// ProxyKindNone is used when the contract is not a known proxy
+	ProxyKindNone ProxyKind = iota
+	// ProxyKindEIP1167 is used for EIP-1167 minimal proxy (clone)
+	ProxyKindEIP1167
+	// ProxyKindEIP1967 is used for EIP-1967 transparent proxy
+	ProxyKindEIP1967
+	// ProxyKindEIP1967Beacon is used for EIP-1967 beacon proxy
+	ProxyKindEIP1967Beacon
+	// ProxyKindUUPS is used for EIP-1822 (UUPS) proxy, i.e. EIP-1967 proxy upgraded by the implementation
+	ProxyKindUUPS
+	// ProxyKindSafe is used for Safe proxy keeping the singleton at storage slot 0
+	ProxyKindSafe
+)
+
+// String returns human-readable name of the proxy kind
+func (k ProxyKind) String() string {
+	switch k {
+	case ProxyKindEIP1167:
+		return "eip1167"
+	case ProxyKindEIP1967:
+		return "eip1967"
+	case ProxyKindEIP1967Beacon:
+		return "eip1967_beacon"
+	case ProxyKindUUPS:
+		return "uups"
+	case ProxyKindSafe:
+		return "safe"
+	default:
+		return "none"
+	}
+}
+
+// WalletFamily is the best-effort guess of the wallet implementation
+type WalletFamily string
+
+// Wallet families Inspect may report, WalletFamilyUnknown is used when the wallet is not recognized
+const (
+	WalletFamilyUnknown      WalletFamily = ""
+	WalletFamilySafe         WalletFamily = "safe"
+	WalletFamilyArgent       WalletFamily = "argent"
+	WalletFamilyAmbire       WalletFamily = "ambire"
+	WalletFamilyKernel       WalletFamily = "kernel"
+	WalletFamilyCoinbase     WalletFamily = "coinbase"
+	WalletFamilyBiconomy     WalletFamily = "biconomy"
+	WalletFamilyLightAccount WalletFamily = "light_account"
+)
+
+// WalletRegistry maps wallet implementations (or non-proxy wallets) to the wallet families by code hash or address
+type WalletRegistry struct {
+	// CodeHashes maps keccak256 of the runtime code to the wallet family
+	CodeHashes map[common.Hash]WalletFamily
+	// Addresses maps the implementation (or non-proxy wallet) address to the wallet family
+	Addresses map[common.Address]WalletFamily
+}
+
+// DefaultWalletRegistry holds canonical implementations of the wallet families, deployed at the same addresses on
+// every chain the vendors support (Argent implementation is Ethereum mainnet one)
+//
+// Implementations are keyed by address rather than code hash: runtime code of the same deployment differs between
+// chains with immutable arguments (i.e. EntryPoint address), while the deterministic address does not
+var DefaultWalletRegistry = WalletRegistry{
+	Addresses: map[common.Address]WalletFamily{
+		// Argent BaseWallet
+		common.HexToAddress("0xb1dd690cc9af7bb1a906a9b5a94f94191cc553ce"): WalletFamilyArgent,
+		// AmbireAccount
+		common.HexToAddress("0x0f2aa7bcda3d9d210df69a394b6965cb2566c828"): WalletFamilyAmbire,
+		// Kernel v2.1, v3.0 and v3.1
+		common.HexToAddress("0xf048AD83CB2dfd6037A43902a2A5Be04e53cd2Eb"): WalletFamilyKernel,
+		common.HexToAddress("0x94F097E1ebEB4ecA3AAE54cabb08905B239A7D27"): WalletFamilyKernel,
+		common.HexToAddress("0xBAC849bB641841b44E965fB01A4Bf5F074f84b4D"): WalletFamilyKernel,
+		// Coinbase Smart Wallet v1
+		common.HexToAddress("0x000100abaad02f1cfC8Bbe32bD5a564817339E72"): WalletFamilyCoinbase,
+		// Biconomy Smart Account v2
+		common.HexToAddress("0x0000002512019Dafb59528B82CB92D3c5D2423aC"): WalletFamilyBiconomy,
+		// LightAccount v1.1.0 and v2.0.0
+		common.HexToAddress("0xae8c656ad28F2B59a196AB61815C16A0AE1c3cba"): WalletFamilyLightAccount,
+		common.HexToAddress("0x8E8e658E22B12ada97B402fF0b044D6A325013C7"): WalletFamilyLightAccount,
+	},
+}
+
+// family returns the wallet family of the account by code hashes and addresses of its implementation and itself
+func (r WalletRegistry) family(res *AccountInspection) (WalletFamily, bool) {
+	for _, hash := range []common.Hash{res.ImplementationCodeHash, res.CodeHash} {
+		if family, ok := r.CodeHashes[hash]; ok && hash != (common.Hash{}) {
+			return family, true
+		}
+	}
+
+	for _, address := range []common.Address{res.Implementation, res.Address} {
+		if family, ok := r.Addresses[address]; ok && !IsZeroAddress(address) {
+			return family, true
+		}
+	}
+
+	return WalletFamilyUnknown, false
+}
+
+var (
+	// eip1167Prefix and eip1167Suffix surround the implementation address in EIP-1167 minimal proxy code
+	eip1167Prefix = common.FromHex("0x363d3d373d3d3d363d73")
+	eip1167Suffix = common.FromHex("0x5af43d82803e903d91602b57fd5bf3")
+
+	// eip1967ImplementationSlot is bytes32(uint256(keccak256("eip1967.proxy.implementation")) - 1)
+	eip1967ImplementationSlot = common.HexToHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc")
+	// eip1967BeaconSlot is bytes32(uint256(keccak256("eip1967.proxy.beacon")) - 1)
+	eip1967BeaconSlot = common.HexToHash("0xa3f0ad74e5423aebfd80d3ef4346578335a9a72aeaee59ff6cb3582b35133d50")
+	// eip1822ProxiableSlot is keccak256("PROXIABLE")
+	eip1822ProxiableSlot = crypto.Keccak256Hash([]byte("PROXIABLE"))
+
+	// proxiableUUIDSelector is the selector of proxiableUUID()
+	proxiableUUIDSelector = crypto.Keccak256([]byte("proxiableUUID()"))[:4]
+	// implementationSelector is the selector of beacon implementation()
+	implementationSelector = crypto.Keccak256([]byte("implementation()"))[:4]
+
+	// errStorageUnavailable is returned when neither the client nor raw rpc client can read storage
+	errStorageUnavailable = errors.New("storage reads require client implementing StorageAt or raw rpc client")
+)
+
+// storageReader is implemented by the clients able to read storage slots (e.g. ethclient.Client)
+type storageReader interface {
+	StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error)
+}
+
+// AccountInspection describes the account as seen by Inspect
+type AccountInspection struct {
+	Address  common.Address
+	Kind     AccountKind
+	CodeSize int
+	// CodeHash is keccak256 of the code (zero for EOA)
+	CodeHash common.Hash
+	Proxy    ProxyKind
+	// Implementation is the contract the code is delegated to: proxy implementation, Safe singleton or EIP-7702 delegate
+	Implementation common.Address
+	// ImplementationCodeHash is keccak256 of the implementation code (zero if there is no implementation)
+	ImplementationCodeHash common.Hash
+	// SupportsERC1271 is ERC-165 supportsInterface(0x1626ba7e) result
+	SupportsERC1271 bool
+	// Safe is the Safe wallet description (nil unless the account is a Safe)
+	Safe   *SafeInfo
+	Family WalletFamily
+}
+
+// WithWalletRegistry extends DefaultWalletRegistry Inspect guesses wallet family by, entries of the registry take
+// precedence over the default ones
+func (v *Validator) WithWalletRegistry(registry WalletRegistry) *Validator {
+	v.walletRegistry = registry
+	return v
+}
+
+// Inspect reports what kind of wallet the address is: code size and hash, proxy pattern and resolved implementation,
+// ERC-165 support of ERC-1271 and the wallet family guess (Safe is detected by calls, other families by the registry)
+//
+// EIP-1967 and UUPS proxies are only detected if storage can be read (the client implements StorageAt, e.g.
+// ethclient.Client, or raw rpc client is set with WithRPCClient)
+func (v *Validator) Inspect(ctx context.Context, address common.Address) (*AccountInspection, error) {
+	if v.err != nil {
+		return nil, v.err
+	}
+
+	blockNumber, _, err := v.resolveBlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := v.codeAt(ctx, address, blockNumber)
+	if err != nil {
+		return nil, &RPCError{Method: "eth_getCode", Err: err}
+	}
+
+	res := &AccountInspection{Address: address, CodeSize: len(code)}
+	res.Kind, res.Implementation = classifyCode(code)
+	if res.Kind == AccountKindEOA {
+		return res, nil
+	}
+	res.CodeHash = crypto.Keccak256Hash(code)
+
+	if res.Kind == AccountKindContract {
+		if err := v.inspectProxy(ctx, res, code, blockNumber); err != nil {
+			return nil, err
+		}
+	}
+
+	if !IsZeroAddress(res.Implementation) {
+		implementationCode, err := v.codeAt(ctx, res.Implementation, blockNumber)
+		if err != nil {
+			return nil, &RPCError{Method: "eth_getCode", Err: err}
+		}
+		if len(implementationCode) > 0 {
+			res.ImplementationCodeHash = crypto.Keccak256Hash(implementationCode)
+		}
+	}
+
+	if res.SupportsERC1271, err = v.supportsInterface(ctx, address, blockNumber, ERC1271InterfaceID); err != nil {
+		return nil, err
+	}
+
+	res.Family = v.walletFamily(res)
+	return res, nil
+}
+
+// inspectProxy detects proxy pattern of the contract and Safe wallet
+func (v *Validator) inspectProxy(ctx context.Context, res *AccountInspection, code []byte, blockNumber *big.Int) error {
+	logger := gaelogrus.GetLogger(ctx).WithField("func", "Inspect").WithField("address", res.Address)
+	if implementation, ok := parseEIP1167(code); ok {
+		res.Proxy, res.Implementation = ProxyKindEIP1167, implementation
+	} else if err := v.inspectStorageProxy(ctx, res, blockNumber); errors.Is(err, errStorageUnavailable) {
+		logger.WithError(err).Debug("skipping storage based proxy detection")
+	} else if err != nil {
+		return err
+	}
+
+	info, err := v.safeInfo(ctx, res.Address, blockNumber)
+	if errors.Is(err, ErrNotSafe) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	res.Safe = info
+	if res.Proxy == ProxyKindNone && !IsZeroAddress(info.Singleton) {
+		res.Proxy, res.Implementation = ProxyKindSafe, info.Singleton
+	}
+
+	return nil
+}
+
+// inspectStorageProxy detects EIP-1967 (transparent, beacon and UUPS) and EIP-1822 proxies by their storage slots
+func (v *Validator) inspectStorageProxy(ctx context.Context, res *AccountInspection, blockNumber *big.Int) error {
+	slot, err := v.storageAt(ctx, res.Address, eip1967ImplementationSlot, blockNumber)
+	if err != nil {
+		return err
+	}
+	if implementation := common.BytesToAddress(slot.Bytes()); !IsZeroAddress(implementation) {
+		res.Proxy, res.Implementation = ProxyKindEIP1967, implementation
+
+		out, err := v.inspectCall(ctx, implementation, proxiableUUIDSelector, blockNumber)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(out, eip1967ImplementationSlot.Bytes()) {
+			res.Proxy = ProxyKindUUPS
+		}
+		return nil
+	}
+
+	slot, err = v.storageAt(ctx, res.Address, eip1967BeaconSlot, blockNumber)
+	if err != nil {
+		return err
+	}
+	if beacon := common.BytesToAddress(slot.Bytes()); !IsZeroAddress(beacon) {
+		out, err := v.inspectCall(ctx, beacon, implementationSelector, blockNumber)
+		if err != nil {
+			return err
+		}
+		res.Proxy = ProxyKindEIP1967Beacon
+		if len(out) == 32 {
+			res.Implementation = common.BytesToAddress(out)
+		}
+		return nil
+	}
+
+	slot, err = v.storageAt(ctx, res.Address, eip1822ProxiableSlot, blockNumber)
+	if err != nil {
+		return err
+	}
+	if implementation := common.BytesToAddress(slot.Bytes()); !IsZeroAddress(implementation) {
+		res.Proxy, res.Implementation = ProxyKindUUPS, implementation
+	}
+
+	return nil
+}
+
+// inspectCall calls the contract with call data, execution failures are reported as empty output
+func (v *Validator) inspectCall(ctx context.Context, address common.Address, callData []byte, blockNumber *big.Int) ([]byte, error) {
+	out, err := v.callContract(ctx, ethereum.CallMsg{To: &address, Gas: callGas(v.gasLimit, callData), Data: callData}, blockNumber)
+	if IsExecutionError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &RPCError{Method: "eth_call", Err: err}
+	}
+
+	return out, nil
+}
+
+// walletFamily guesses wallet family by the registries and Safe detection
+func (v *Validator) walletFamily(res *AccountInspection) WalletFamily {
+	for _, registry := range []WalletRegistry{v.walletRegistry, DefaultWalletRegistry} {
+		if family, ok := registry.family(res); ok {
+			return family
+		}
+	}
+
+	if res.Safe != nil {
+		return WalletFamilySafe
+	}
+
+	return WalletFamilyUnknown
+}
+
+// parseEIP1167 returns the implementation address if the code is EIP-1167 minimal proxy
+func parseEIP1167(code []byte) (common.Address, bool) {
+	if len(code) != len(eip1167Prefix)+common.AddressLength+len(eip1167Suffix) ||
+		!bytes.HasPrefix(code, eip1167Prefix) || !bytes.HasSuffix(code, eip1167Suffix) {
+		return common.Address{}, false
+	}
+
+	return common.BytesToAddress(code[len(eip1167Prefix) : len(eip1167Prefix)+common.AddressLength]), true
+}
+
+// storageAt reads the storage slot with the client (if it implements StorageAt) or raw rpc client
+func (v *Validator) storageAt(ctx context.Context, address common.Address, slot common.Hash, blockNumber *big.Int) (common.Hash, error) {
+	reader, ok := v.client.(storageReader)
+	ok = ok && !v.pinnedToHash()
+	if !ok && v.rpcClient == nil {
+		return common.Hash{}, errStorageUnavailable
+	}
+
+	var value []byte
+	err := v.retry(ctx, "eth_getStorageAt", func() error {
+		callCtx, cancel := v.callContext(ctx)
+		defer cancel()
+
+		if ok {
+			out, err := reader.StorageAt(callCtx, address, slot, blockNumber)
+			value = out
+			return err
+		}
+
+		var out hexutil.Bytes
+		err := v.rpcClient.CallContext(callCtx, &out, "eth_getStorageAt", address, slot, v.blockArg(blockNumber))
+		value = out
+		return err
+	})
+	if err != nil {
+		return common.Hash{}, &RPCError{Method: "eth_getStorageAt", Err: err}
+	}
+
+	return common.BytesToHash(value), nil
+}
@@ -0,0 +1,159 @@
+package erc1271
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// mockStorageCaller is mockCaller able to read storage, contract calls are answered out of responses keyed by the
+// address and the first 8 bytes of call data (selector and the first argument prefix), other calls revert
+type mockStorageCaller struct {
+	mockCaller
+	storage   map[common.Address]map[common.Hash]common.Hash
+	responses map[common.Address]map[string][]byte
+}
+
+func newMockStorageCaller() *mockStorageCaller {
+	m := &mockStorageCaller{
+		mockCaller: mockCaller{code: make(map[common.Address][]byte)},
+		storage:    make(map[common.Address]map[common.Hash]common.Hash),
+		responses:  make(map[common.Address]map[string][]byte),
+	}
+	m.call = func(msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+		key := msg.Data
+		if len(key) > 8 {
+			key = key[:8]
+		}
+
+		out, ok := m.responses[*msg.To][hexutil.Encode(key)]
+		if !ok {
+			return nil, &revertError{}
+		}
+		return out, nil
+	}
+	return m
+}
+
+func (m *mockStorageCaller) StorageAt(_ context.Context, account common.Address, key common.Hash, _ *big.Int) ([]byte, error) {
+	value := m.storage[account][key]
+	return value.Bytes(), nil
+}
+
+// respond sets the response to the call data prefix
+func (m *mockStorageCaller) respond(address common.Address, prefix []byte, out []byte) {
+	if m.responses[address] == nil {
+		m.responses[address] = make(map[string][]byte)
+	}
+	m.responses[address][hexutil.Encode(prefix)] = out
+}
+
+// store sets the storage slot
+func (m *mockStorageCaller) store(address common.Address, slot common.Hash, value common.Hash) {
+	if m.storage[address] == nil {
+		m.storage[address] = make(map[common.Hash]common.Hash)
+	}
+	m.storage[address][slot] = value
+}
+
+func TestInspect(t *testing.T) {
+	ctx := context.Background()
+	client := newMockStorageCaller()
+	implementationCode := []byte{0x60, 0x01}
+	proxyCode := []byte{0x60, 0x02}
+	address := func(i int64) common.Address {
+		return common.BigToAddress(big.NewInt(0x1000 + i))
+	}
+
+	implementation, eoa, clone, transparent, uups, beaconProxy, beacon, delegated, safeProxy, safeSingleton :=
+		address(0), address(1), address(2), address(3), address(4), address(5), address(6), address(7), address(8), address(9)
+
+	client.code[implementation] = implementationCode
+	client.code[safeSingleton] = []byte{0x60, 0x03}
+	client.code[clone] = append(append(common.CopyBytes(eip1167Prefix), implementation.Bytes()...), eip1167Suffix...)
+	client.code[delegated] = append(common.CopyBytes(DelegationPrefix), implementation.Bytes()...)
+	for _, proxy := range []common.Address{transparent, uups, beaconProxy, safeProxy} {
+		client.code[proxy] = proxyCode
+	}
+	client.code[beacon] = proxyCode
+
+	client.store(transparent, eip1967ImplementationSlot, common.BytesToHash(implementation.Bytes()))
+	uupsImplementation := address(10)
+	client.code[uupsImplementation] = []byte{0x60, 0x04}
+	client.store(uups, eip1967ImplementationSlot, common.BytesToHash(uupsImplementation.Bytes()))
+	client.respond(uupsImplementation, proxiableUUIDSelector, eip1967ImplementationSlot.Bytes())
+	client.store(beaconProxy, eip1967BeaconSlot, common.BytesToHash(beacon.Bytes()))
+	client.respond(beacon, implementationSelector, common.LeftPadBytes(implementation.Bytes(), 32))
+
+	// the clone supports ERC-165 and ERC-1271
+	supports := func(interfaceID [4]byte, supported bool) {
+		out := make([]byte, 32)
+		if supported {
+			out[31] = 1
+		}
+		client.respond(clone, append([]byte{0x01, 0xff, 0xc9, 0xa7}, interfaceID[:]...), out)
+	}
+	supports(erc165InterfaceID, true)
+	supports(erc165InvalidInterfaceID, false)
+	supports(ERC1271InterfaceID, true)
+
+	client.store(safeProxy, common.Hash{}, common.BytesToHash(safeSingleton.Bytes()))
+	version, _ := safe.Methods["VERSION"].Outputs.Pack("1.3.0")
+	owners, _ := safe.Methods["getOwners"].Outputs.Pack([]common.Address{eoa})
+	threshold, _ := safe.Methods["getThreshold"].Outputs.Pack(big.NewInt(1))
+	client.respond(safeProxy, safe.Methods["VERSION"].ID, version)
+	client.respond(safeProxy, safe.Methods["getOwners"].ID, owners)
+	client.respond(safeProxy, safe.Methods["getThreshold"].ID, threshold)
+	client.respond(safeProxy, safe.Methods["domainSeparator"].ID, common.HexToHash("0x01").Bytes())
+
+	// the clone of canonical Coinbase Smart Wallet implementation is recognized by the default registry
+	coinbaseImplementation := common.HexToAddress("0x000100abaad02f1cfC8Bbe32bD5a564817339E72")
+	coinbaseClone := address(11)
+	client.code[coinbaseImplementation] = []byte{0x60, 0x05}
+	client.code[coinbaseClone] = append(append(common.CopyBytes(eip1167Prefix), coinbaseImplementation.Bytes()...), eip1167Suffix...)
+
+	registry := WalletRegistry{CodeHashes: map[common.Hash]WalletFamily{crypto.Keccak256Hash(implementationCode): WalletFamilyKernel}}
+	validator := NewValidator(client).WithWalletRegistry(registry)
+
+	type Case struct {
+		Description     string
+		Address         common.Address
+		Kind            AccountKind
+		Proxy           ProxyKind
+		Implementation  common.Address
+		SupportsERC1271 bool
+		Family          WalletFamily
+	}
+
+	tests := []Case{
+		{"EOA", eoa, AccountKindEOA, ProxyKindNone, common.Address{}, false, WalletFamilyUnknown},
+		{"EIP-1167 clone", clone, AccountKindContract, ProxyKindEIP1167, implementation, true, WalletFamilyKernel},
+		{"EIP-1967 proxy", transparent, AccountKindContract, ProxyKindEIP1967, implementation, false, WalletFamilyKernel},
+		{"UUPS proxy", uups, AccountKindContract, ProxyKindUUPS, uupsImplementation, false, WalletFamilyUnknown},
+		{"Beacon proxy", beaconProxy, AccountKindContract, ProxyKindEIP1967Beacon, implementation, false, WalletFamilyKernel},
+		{"Delegated EOA", delegated, AccountKindDelegatedEOA, ProxyKindNone, implementation, false, WalletFamilyKernel},
+		{"Default registry implementation", coinbaseClone, AccountKindContract, ProxyKindEIP1167, coinbaseImplementation, false, WalletFamilyCoinbase},
+		{"Safe proxy", safeProxy, AccountKindContract, ProxyKindSafe, safeSingleton, false, WalletFamilySafe},
+	}
+
+	for i, test := range tests {
+		res, err := validator.Inspect(ctx, test.Address)
+		if err != nil {
+			t.Errorf("%d (%s): expected err to be nil, got: %s", i, test.Description, err)
+			continue
+		}
+
+		if res.Kind != test.Kind || res.Proxy != test.Proxy || res.Implementation != test.Implementation {
+			t.Errorf("%d (%s): expected account to be %s (%s, %s), got: %s (%s, %s)", i, test.Description, test.Kind, test.Proxy, test.Implementation.Hex(), res.Kind, res.Proxy, res.Implementation.Hex())
+		}
+
+		if res.SupportsERC1271 != test.SupportsERC1271 || res.Family != test.Family {
+			t.Errorf("%d (%s): expected erc1271 support and family to be %t (%q), got: %t (%q)", i, test.Description, test.SupportsERC1271, test.Family, res.SupportsERC1271, res.Family)
+		}
+	}
+}
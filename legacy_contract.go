@@ -0,0 +1,212 @@
+package erc1271
+
+// Code generated - DO NOT EDIT.
+// This file is a generated binding and any manual changes will be lost.
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var (
+	_ = errors.New
+	_ = big.NewInt
+	_ = strings.NewReader
+	_ = ethereum.NotFound
+	_ = bind.Bind
+	_ = common.Big1
+	_ = types.BloomLookup
+	_ = event.NewSubscription
+	_ = abi.ConvertType
+)
+
+// LegacyContractMetaData contains all meta data concerning the LegacyContract contract.
+var LegacyContractMetaData = &bind.MetaData{
+	ABI: "[{\"inputs\":[{\"internalType\":\"bytes\",\"name\":\"_data\",\"type\":\"bytes\"},{\"internalType\":\"bytes\",\"name\":\"_signature\",\"type\":\"bytes\"}],\"name\":\"isValidSignature\",\"outputs\":[{\"internalType\":\"bytes4\",\"name\":\"magicValue\",\"type\":\"bytes4\"}],\"stateMutability\":\"view\",\"type\":\"function\"}]",
+}
+
+// LegacyContractABI is the input ABI used to generate the binding from.
+// Deprecated: Use LegacyContractMetaData.ABI instead.
+var LegacyContractABI = LegacyContractMetaData.ABI
+
+// LegacyContract is an auto generated Go binding around an Ethereum contract.
+type LegacyContract struct {
+	LegacyContractCaller     // Read-only binding to the contract
+	LegacyContractTransactor // Write-only binding to the contract
+	LegacyContractFilterer   // Log filterer for contract events
+}
+
+// LegacyContractCaller is an auto generated read-only Go binding around an Ethereum contract.
+type LegacyContractCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// LegacyContractTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type LegacyContractTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// LegacyContractFilterer is an auto generated log filtering Go binding around an Ethereum contract events.
+type LegacyContractFilterer struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// LegacyContractSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type LegacyContractSession struct {
+	Contract     *LegacyContract   // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// LegacyContractCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type LegacyContractCallerSession struct {
+	Contract *LegacyContractCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts         // Call options to use throughout this session
+}
+
+// LegacyContractTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type LegacyContractTransactorSession struct {
+	Contract     *LegacyContractTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts         // Transaction auth options to use throughout this session
+}
+
+// LegacyContractRaw is an auto generated low-level Go binding around an Ethereum contract.
+type LegacyContractRaw struct {
+	Contract *LegacyContract // Generic contract binding to access the raw methods on
+}
+
+// LegacyContractCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type LegacyContractCallerRaw struct {
+	Contract *LegacyContractCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// LegacyContractTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type LegacyContractTransactorRaw struct {
+	Contract *LegacyContractTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewLegacyContract creates a new instance of LegacyContract, bound to a specific deployed contract.
+func NewLegacyContract(address common.Address, backend bind.ContractBackend) (*LegacyContract, error) {
+	contract, err := bindLegacyContract(address, backend, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &LegacyContract{LegacyContractCaller: LegacyContractCaller{contract: contract}, LegacyContractTransactor: LegacyContractTransactor{contract: contract}, LegacyContractFilterer: LegacyContractFilterer{contract: contract}}, nil
+}
+
+// NewLegacyContractCaller creates a new read-only instance of LegacyContract, bound to a specific deployed contract.
+func NewLegacyContractCaller(address common.Address, caller bind.ContractCaller) (*LegacyContractCaller, error) {
+	contract, err := bindLegacyContract(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &LegacyContractCaller{contract: contract}, nil
+}
+
+// NewLegacyContractTransactor creates a new write-only instance of LegacyContract, bound to a specific deployed contract.
+func NewLegacyContractTransactor(address common.Address, transactor bind.ContractTransactor) (*LegacyContractTransactor, error) {
+	contract, err := bindLegacyContract(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &LegacyContractTransactor{contract: contract}, nil
+}
+
+// NewLegacyContractFilterer creates a new log filterer instance of LegacyContract, bound to a specific deployed contract.
+func NewLegacyContractFilterer(address common.Address, filterer bind.ContractFilterer) (*LegacyContractFilterer, error) {
+	contract, err := bindLegacyContract(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &LegacyContractFilterer{contract: contract}, nil
+}
+
+// bindLegacyContract binds a generic wrapper to an already deployed contract.
+func bindLegacyContract(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
+	parsed, err := LegacyContractMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, *parsed, caller, transactor, filterer), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_LegacyContract *LegacyContractRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _LegacyContract.Contract.LegacyContractCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_LegacyContract *LegacyContractRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _LegacyContract.Contract.LegacyContractTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_LegacyContract *LegacyContractRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _LegacyContract.Contract.LegacyContractTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_LegacyContract *LegacyContractCallerRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _LegacyContract.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_LegacyContract *LegacyContractTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _LegacyContract.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_LegacyContract *LegacyContractTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _LegacyContract.Contract.contract.Transact(opts, method, params...)
+}
+
+// IsValidSignature is a free data retrieval call binding the contract method 0x20c13b0b.
+//
+// Solidity: function isValidSignature(bytes _data, bytes _signature) view returns(bytes4 magicValue)
+func (_LegacyContract *LegacyContractCaller) IsValidSignature(opts *bind.CallOpts, _data []byte, _signature []byte) ([4]byte, error) {
+	var out []interface{}
+	err := _LegacyContract.contract.Call(opts, &out, "isValidSignature", _data, _signature)
+
+	if err != nil {
+		return *new([4]byte), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new([4]byte)).(*[4]byte)
+
+	return out0, err
+
+}
+
+// IsValidSignature is a free data retrieval call binding the contract method 0x20c13b0b.
+//
+// Solidity: function isValidSignature(bytes _data, bytes _signature) view returns(bytes4 magicValue)
+func (_LegacyContract *LegacyContractSession) IsValidSignature(_data []byte, _signature []byte) ([4]byte, error) {
+	return _LegacyContract.Contract.IsValidSignature(&_LegacyContract.CallOpts, _data, _signature)
+}
+
+// IsValidSignature is a free data retrieval call binding the contract method 0x20c13b0b.
+//
+// Solidity: function isValidSignature(bytes _data, bytes _signature) view returns(bytes4 magicValue)
+func (_LegacyContract *LegacyContractCallerSession) IsValidSignature(_data []byte, _signature []byte) ([4]byte, error) {
+	return _LegacyContract.Contract.IsValidSignature(&_LegacyContract.CallOpts, _data, _signature)
+}
@@ -0,0 +1,229 @@
+package erc1271
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// DefaultLocalGasLimit is the gas available to LocalCaller calls without explicit gas, matches the default gas cap of
+// go-ethereum nodes
+const DefaultLocalGasLimit = 50_000_000
+
+// LocalCaller is bind.ContractCaller executing the calls in go-ethereum EVM against the supplied state snapshot, so
+// the Validator works without RPC at all
+//
+// Every call is executed against a fresh copy of the snapshot. Unless the block context is set, all the forks
+// supported by go-ethereum are enabled and the current time is used as the block timestamp
+//
+// go-ethereum v1.10.22 EVM implements the forks up to London (and The Merge), PUSH0 of Shanghai (EIP-3855, emitted by
+// solc 0.8.20+ by default) is enabled on top of them. Cancun opcodes (TLOAD, TSTORE, MCOPY, BLOBHASH, BLOBBASEFEE) are
+// not supported, so wallets using them fail locally with invalid opcode while succeeding on-chain
+type LocalCaller struct {
+	db          state.Database
+	root        common.Hash
+	chainConfig *params.ChainConfig
+	blockNumber *big.Int
+	time        *big.Int
+	baseFee     *big.Int
+}
+
+// LoadStateDump decodes JSON state dump in go-ethereum format (geth dump, debug_dumpBlock)
+func LoadStateDump(r io.Reader) (*state.Dump, error) {
+	dump := new(state.Dump)
+	if err := json.NewDecoder(r).Decode(dump); err != nil {
+		return nil, fmt.Errorf("failed to decode state dump: %w", err)
+	}
+
+	return dump, nil
+}
+
+// NewLocalCaller creates a new LocalCaller instance out of the state dump, chain ID defaults to 1 (mainnet)
+func NewLocalCaller(dump *state.Dump) (*LocalCaller, error) {
+	db := state.NewDatabase(rawdb.NewMemoryDatabase())
+	statedb, err := state.New(common.Hash{}, db, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for address, account := range dump.Accounts {
+		if account.Balance != "" {
+			balance, ok := new(big.Int).SetString(account.Balance, 0)
+			if !ok {
+				return nil, fmt.Errorf("invalid balance of %s: %q", address.Hex(), account.Balance)
+			}
+			statedb.SetBalance(address, balance)
+		}
+
+		statedb.SetNonce(address, account.Nonce)
+		statedb.SetCode(address, account.Code)
+		for key, value := range account.Storage {
+			statedb.SetState(address, key, common.HexToHash(value))
+		}
+	}
+
+	root, err := statedb.Commit(false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalCaller{db: db, root: root, chainConfig: localChainConfig(big.NewInt(1))}, nil
+}
+
+// WithChainID sets chain ID returned by CHAINID opcode (used by EIP-712 domains of the wallets)
+func (c *LocalCaller) WithChainID(chainID *big.Int) *LocalCaller {
+	c.chainConfig = localChainConfig(chainID)
+	return c
+}
+
+// WithChainConfig sets chain config the forks are enabled by
+func (c *LocalCaller) WithChainConfig(chainConfig *params.ChainConfig) *LocalCaller {
+	c.chainConfig = chainConfig
+	return c
+}
+
+// WithBlockContext sets the block the snapshot is taken at, calls pinned to other blocks fail
+func (c *LocalCaller) WithBlockContext(number *big.Int, timestamp uint64, baseFee *big.Int) *LocalCaller {
+	c.blockNumber = number
+	c.time = new(big.Int).SetUint64(timestamp)
+	c.baseFee = baseFee
+	return c
+}
+
+// ChainID returns configured chain ID
+func (c *LocalCaller) ChainID(_ context.Context) (*big.Int, error) {
+	return c.chainConfig.ChainID, nil
+}
+
+// CodeAt implements bind.ContractCaller
+func (c *LocalCaller) CodeAt(_ context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	statedb, err := c.state(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return statedb.GetCode(contract), nil
+}
+
+// CallContract implements bind.ContractCaller, EVM failures are reported the same way nodes do (reverts carry revert
+// data)
+func (c *LocalCaller) CallContract(_ context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return c.call(msg, blockNumber, nil)
+}
+
+// call executes the call with optional tracer
+func (c *LocalCaller) call(msg ethereum.CallMsg, blockNumber *big.Int, tracer vm.EVMLogger) ([]byte, error) {
+	statedb, err := c.state(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &runtime.Config{
+		ChainConfig: c.chainConfig,
+		Origin:      msg.From,
+		BlockNumber: c.blockNumber,
+		Time:        c.time,
+		BaseFee:     c.baseFee,
+		GasLimit:    msg.Gas,
+		Value:       msg.Value,
+		State:       statedb,
+	}
+	// the slice is modified by the interpreter if the activation fails, so it is never shared
+	cfg.EVMConfig = vm.Config{ExtraEips: append([]int(nil), localExtraEIPs...)}
+	if tracer != nil {
+		cfg.EVMConfig.Debug, cfg.EVMConfig.Tracer = true, tracer
+	}
+	if cfg.GasLimit == 0 {
+		cfg.GasLimit = DefaultLocalGasLimit
+	}
+
+	var out []byte
+	if msg.To == nil {
+		out, _, _, err = runtime.Create(msg.Data, cfg)
+	} else {
+		out, _, err = runtime.Call(*msg.To, msg.Data, cfg)
+	}
+	if err != nil {
+		return nil, &localExecutionError{err: err, data: out}
+	}
+
+	return out, nil
+}
+
+// state returns a fresh copy of the snapshot
+func (c *LocalCaller) state(blockNumber *big.Int) (*state.StateDB, error) {
+	if blockNumber != nil && blockNumber.Sign() >= 0 && c.blockNumber != nil && blockNumber.Cmp(c.blockNumber) != 0 {
+		return nil, fmt.Errorf("state snapshot is taken at block %s, requested block %s", c.blockNumber, blockNumber)
+	}
+
+	return state.New(c.root, c.db, nil)
+}
+
+// localExtraEIPs are enabled on top of the forks of the chain config, go-ethereum v1.10.22 does not activate them
+// with Shanghai fork block
+var localExtraEIPs = []int{3855}
+
+// localChainConfig returns the config with all the forks enabled (the same as core/vm/runtime default) and chain ID
+func localChainConfig(chainID *big.Int) *params.ChainConfig {
+	return &params.ChainConfig{
+		ChainID:             chainID,
+		HomesteadBlock:      new(big.Int),
+		DAOForkBlock:        new(big.Int),
+		EIP150Block:         new(big.Int),
+		EIP155Block:         new(big.Int),
+		EIP158Block:         new(big.Int),
+		ByzantiumBlock:      new(big.Int),
+		ConstantinopleBlock: new(big.Int),
+		PetersburgBlock:     new(big.Int),
+		IstanbulBlock:       new(big.Int),
+		MuirGlacierBlock:    new(big.Int),
+		BerlinBlock:         new(big.Int),
+		LondonBlock:         new(big.Int),
+	}
+}
+
+// localExecutionError mimics JSON-RPC execution error of the nodes
+type localExecutionError struct {
+	err  error
+	data []byte
+}
+
+// Error implements error interface
+func (e *localExecutionError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying EVM error
+func (e *localExecutionError) Unwrap() error {
+	return e.err
+}
+
+// ErrorCode implements rpc.Error, 3 is the code of execution reverted error
+func (e *localExecutionError) ErrorCode() int {
+	if errors.Is(e.err, vm.ErrExecutionReverted) {
+		return 3
+	}
+
+	return -32000
+}
+
+// ErrorData implements rpc.DataError
+func (e *localExecutionError) ErrorData() interface{} {
+	if len(e.data) == 0 {
+		return nil
+	}
+
+	return hexutil.Encode(e.data)
+}
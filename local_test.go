@@ -0,0 +1,76 @@
+package erc1271
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestLocalCaller(t *testing.T) {
+	ctx := context.Background()
+	expectedHash := crypto.Keccak256Hash([]byte("Hello go test!"))
+	wallet := common.HexToAddress("0x607377F587B1BDc68Bec3E19316D56bA8929d5eB")
+	eoa := common.HexToAddress("0x5C6Aa53c883bB6c66CD2A0aD42Ae0828832A40E0")
+	push0Wallet := common.HexToAddress("0x8Ba1f109551bD432803012645Ac136ddd64DBA72")
+
+	// runtime code returning magic value only for the hash stored at slot 0, reverting otherwise
+	walletCode := []byte{0x60, 0x00, 0x54, 0x60, 0x04, 0x35, 0x14, 0x60, 0x0f, 0x57, 0x60, 0x00, 0x60, 0x00, 0xfd, 0x5b, 0x7f}
+	walletCode = append(walletCode, common.RightPadBytes(ValidSignature, 32)...)
+	walletCode = append(walletCode, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3)
+
+	// runtime code compiled for Shanghai, returns magic value using PUSH0
+	push0WalletCode := append([]byte{0x7f}, common.RightPadBytes(ValidSignature, 32)...)
+	push0WalletCode = append(push0WalletCode, 0x5f, 0x52, 0x60, 0x20, 0x5f, 0xf3)
+
+	dump := fmt.Sprintf(`{
+		"root": "0x0000000000000000000000000000000000000000000000000000000000000000",
+		"accounts": {
+			"%s": {"balance": "0", "nonce": 1, "code": "%s", "storage": {"0x0000000000000000000000000000000000000000000000000000000000000000": "%s"}},
+			"%s": {"balance": "1000000000000000000", "nonce": 0},
+			"%s": {"balance": "0", "nonce": 1, "code": "%s"}
+		}
+	}`, wallet.Hex(), hexutil.Encode(walletCode), common.Bytes2Hex(expectedHash.Bytes()), eoa.Hex(), push0Wallet.Hex(), hexutil.Encode(push0WalletCode))
+
+	state, err := LoadStateDump(strings.NewReader(dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewLocalCaller(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	validator := NewValidator(client)
+
+	type Case struct {
+		Description string
+		Hash        common.Hash
+		Signer      common.Address
+		Valid       bool
+		Status      ValidationStatus
+	}
+
+	tests := []Case{
+		{"Valid signature", expectedHash, wallet, true, ValidationStatusValid},
+		{"Invalid signature (reverted)", crypto.Keccak256Hash([]byte("Hello go test!!")), wallet, false, ValidationStatusReverted},
+		{"Not a contract", expectedHash, eoa, false, ValidationStatusNotContract},
+		{"Valid signature (PUSH0)", expectedHash, push0Wallet, true, ValidationStatusValid},
+	}
+
+	for i, test := range tests {
+		res, err := validator.ValidateHashDetailedBytes(ctx, test.Hash, test.Signer, []byte{0x01})
+		if err != nil {
+			t.Errorf("%d (%s): expected err to be nil, got: %s", i, test.Description, err)
+			continue
+		}
+
+		if res.Valid != test.Valid || res.Status != test.Status {
+			t.Errorf("%d (%s): expected result to be %t (%s), got: %t (%s)", i, test.Description, test.Valid, test.Status, res.Valid, res.Status)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package erc1271
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// mockCaller is an in-memory bind.ContractCaller, contract calls are answered by call function
+type mockCaller struct {
+	code map[common.Address][]byte
+	call func(msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+
+	// codeBlocks records block numbers CodeAt was called with
+	codeBlocks []*big.Int
+}
+
+func (m *mockCaller) CodeAt(_ context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	m.codeBlocks = append(m.codeBlocks, blockNumber)
+	return m.code[contract], nil
+}
+
+func (m *mockCaller) CallContract(_ context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if m.call == nil {
+		return nil, nil
+	}
+
+	return m.call(msg, blockNumber)
+}
+
+// revertError mimics JSON-RPC execution reverted error carrying revert data
+type revertError struct {
+	data []byte
+}
+
+func (e *revertError) Error() string {
+	return "execution reverted"
+}
+
+func (e *revertError) ErrorCode() int {
+	return 3
+}
+
+func (e *revertError) ErrorData() interface{} {
+	return hexutil.Encode(e.data)
+}
+
+// mockRPC is an in-memory RPCCaller answering eth_getBlockByNumber and eth_getBlockByHash with blocks
+type mockRPC struct {
+	blocks map[string]rpcBlock
+}
+
+func (m *mockRPC) CallContext(_ context.Context, result interface{}, method string, args ...interface{}) error {
+	block, ok := m.blocks[fmt.Sprint(args[0])]
+	if !ok {
+		return nil
+	}
+
+	*(result.(**rpcBlock)) = &block
+	return nil
+}
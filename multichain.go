@@ -0,0 +1,153 @@
+package erc1271
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// MultiChainValidator routes validations to the per-chain Validator by chain ID
+type MultiChainValidator struct {
+	validators map[uint64]*Validator
+}
+
+// NewMultiChainValidator creates a new MultiChainValidator instance out of chain ID -> client map, every client gets
+// the default Validator with the chain ID set to the key (use Validator or WithValidator to configure it)
+//
+// Chain IDs of the clients are not checked, use NewCheckedMultiChainValidator or CheckChainIDs to make sure every client
+// is connected to the chain it is registered for
+func NewMultiChainValidator(clients map[uint64]bind.ContractCaller) *MultiChainValidator {
+	m := &MultiChainValidator{validators: make(map[uint64]*Validator, len(clients))}
+	for chainID, client := range clients {
+		m.validators[chainID] = NewValidator(client).WithChainID(new(big.Int).SetUint64(chainID))
+	}
+
+	return m
+}
+
+// NewCheckedMultiChainValidator creates a new MultiChainValidator instance the same way NewMultiChainValidator does and
+// checks chain IDs of the clients with CheckChainIDs, returns ErrChainIDMismatch if any client is connected to a
+// different chain
+func NewCheckedMultiChainValidator(ctx context.Context, clients map[uint64]bind.ContractCaller) (*MultiChainValidator, error) {
+	m := NewMultiChainValidator(clients)
+	if err := m.CheckChainIDs(ctx); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// WithValidator sets custom Validator (e.g. with custom magic value, Multicall address or cache) of the chain, the
+// chain ID is set to the key unless the Validator has it set already
+//
+// The Validator is not checked, call CheckChainIDs after custom validators are set
+func (m *MultiChainValidator) WithValidator(chainID uint64, validator *Validator) *MultiChainValidator {
+	if validator.chainIDState.chainID == nil {
+		validator.WithChainID(new(big.Int).SetUint64(chainID))
+	}
+
+	m.validators[chainID] = validator
+	return m
+}
+
+// Validator returns the Validator of the chain, ErrUnsupportedChain if the chain is not configured
+func (m *MultiChainValidator) Validator(chainID uint64) (*Validator, error) {
+	validator, ok := m.validators[chainID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedChain, chainID)
+	}
+
+	return validator, nil
+}
+
+// ChainIDs returns sorted IDs of the configured chains
+func (m *MultiChainValidator) ChainIDs() []uint64 {
+	chainIDs := make([]uint64, 0, len(m.validators))
+	for chainID := range m.validators {
+		chainIDs = append(chainIDs, chainID)
+	}
+	sort.Slice(chainIDs, func(i, j int) bool { return chainIDs[i] < chainIDs[j] })
+
+	return chainIDs
+}
+
+// CheckChainIDs fetches chain ID of every client and compares it against the key the client is registered with,
+// meant to be called on startup, returns ErrChainIDMismatch if the client is connected to a different chain
+//
+// Clients not implementing ChainID (i.e. not *ethclient.Client) can not be checked, their Validators are checked against
+// the chain ID set with WithChainID instead
+func (m *MultiChainValidator) CheckChainIDs(ctx context.Context) error {
+	for _, chainID := range m.ChainIDs() {
+		validator := m.validators[chainID]
+		fetch := validator.ChainID
+		if _, ok := validator.client.(chainIDReader); ok {
+			fetch = validator.fetchChainID
+		}
+
+		actual, err := fetch(ctx)
+		if err != nil {
+			return fmt.Errorf("chain %d: %w", chainID, err)
+		}
+
+		if actual.Cmp(new(big.Int).SetUint64(chainID)) != 0 {
+			return fmt.Errorf("%w: client of chain %d is connected to chain %s", ErrChainIDMismatch, chainID, actual)
+		}
+	}
+
+	return nil
+}
+
+// Validate performs Validator.Validate on the chain
+func (m *MultiChainValidator) Validate(ctx context.Context, chainID uint64, message []byte, signer string, signature string) (bool, error) {
+	validator, err := m.Validator(chainID)
+	if err != nil {
+		return false, err
+	}
+
+	return validator.Validate(ctx, message, signer, signature)
+}
+
+// ValidateDetailed performs Validator.ValidateDetailed on the chain
+func (m *MultiChainValidator) ValidateDetailed(ctx context.Context, chainID uint64, message []byte, signer string, signature string) (*ValidationResult, error) {
+	validator, err := m.Validator(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	return validator.ValidateDetailed(ctx, message, signer, signature)
+}
+
+// ValidateTypedData performs Validator.ValidateTypedData on the chain
+func (m *MultiChainValidator) ValidateTypedData(ctx context.Context, chainID uint64, typedData apitypes.TypedData, signer string, signature string) (bool, error) {
+	validator, err := m.Validator(chainID)
+	if err != nil {
+		return false, err
+	}
+
+	return validator.ValidateTypedData(ctx, typedData, signer, signature)
+}
+
+// ValidateHash performs Validator.ValidateHash on the chain
+func (m *MultiChainValidator) ValidateHash(ctx context.Context, chainID uint64, hash common.Hash, signer string, signature string) (bool, error) {
+	validator, err := m.Validator(chainID)
+	if err != nil {
+		return false, err
+	}
+
+	return validator.ValidateHash(ctx, hash, signer, signature)
+}
+
+// ValidateBatch performs Validator.ValidateBatch on the chain
+func (m *MultiChainValidator) ValidateBatch(ctx context.Context, chainID uint64, requests []ValidationRequest) ([]ValidationResult, error) {
+	validator, err := m.Validator(chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	return validator.ValidateBatch(ctx, requests), nil
+}
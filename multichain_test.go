@@ -0,0 +1,98 @@
+package erc1271
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// mockChainCaller is mockCaller reporting chain ID
+type mockChainCaller struct {
+	*mockCaller
+	chainID int64
+}
+
+func (m *mockChainCaller) ChainID(_ context.Context) (*big.Int, error) {
+	return big.NewInt(m.chainID), nil
+}
+
+func TestMultiChainValidator(t *testing.T) {
+	ctx := context.Background()
+	hash := crypto.Keccak256Hash([]byte("Hello go test!"))
+	wallet := common.HexToAddress("0x607377F587B1BDc68Bec3E19316D56bA8929d5eB")
+
+	newClient := func(chainID int64, magicValue []byte) *mockChainCaller {
+		return &mockChainCaller{
+			mockCaller: &mockCaller{
+				code: map[common.Address][]byte{wallet: {0x60, 0x80}},
+				call: func(_ ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+					return common.RightPadBytes(magicValue, 32), nil
+				},
+			},
+			chainID: chainID,
+		}
+	}
+
+	customMagicValue := []byte{0x01, 0x02, 0x03, 0x04}
+	validator := NewMultiChainValidator(map[uint64]bind.ContractCaller{
+		1:   newClient(1, ValidSignature),
+		137: newClient(137, customMagicValue),
+	})
+	validator.WithValidator(10, NewValidator(newClient(10, customMagicValue)).WithCustomValidSignature(customMagicValue))
+
+	if err := validator.CheckChainIDs(ctx); err != nil {
+		t.Fatalf("expected err to be nil, got: %s", err)
+	}
+
+	type Case struct {
+		Description string
+		ChainID     uint64
+		Valid       bool
+		Err         error
+	}
+
+	tests := []Case{
+		{"Valid signature", 1, true, nil},
+		{"Invalid signature (different magic value)", 137, false, nil},
+		{"Valid signature (custom magic value)", 10, true, nil},
+		{"Unsupported chain", 5, false, ErrUnsupportedChain},
+	}
+
+	for i, test := range tests {
+		valid, err := validator.ValidateHash(ctx, test.ChainID, hash, wallet.Hex(), "0x01")
+		if !errors.Is(err, test.Err) {
+			t.Errorf("%d (%s): expected err to be %v, got: %v", i, test.Description, test.Err, err)
+			continue
+		}
+
+		if valid != test.Valid {
+			t.Errorf("%d (%s): expected result to be %t, got: %t", i, test.Description, test.Valid, valid)
+		}
+	}
+
+	validator.WithValidator(56, NewValidator(newClient(1, ValidSignature)))
+	if err := validator.CheckChainIDs(ctx); !errors.Is(err, ErrChainIDMismatch) {
+		t.Errorf("expected err to be %s, got: %v", ErrChainIDMismatch, err)
+	}
+
+	if _, err := NewCheckedMultiChainValidator(ctx, map[uint64]bind.ContractCaller{1: newClient(1, ValidSignature), 56: newClient(1, ValidSignature)}); !errors.Is(err, ErrChainIDMismatch) {
+		t.Errorf("expected err to be %s, got: %v", ErrChainIDMismatch, err)
+	}
+
+	// clients not reporting chain ID get the key as the chain ID
+	checked, err := NewCheckedMultiChainValidator(ctx, map[uint64]bind.ContractCaller{1: newClient(1, ValidSignature), 100: &mockCaller{}})
+	if err != nil {
+		t.Fatalf("expected err to be nil, got: %s", err)
+	}
+
+	gnosis, _ := checked.Validator(100)
+	if chainID, err := gnosis.ChainID(ctx); err != nil || chainID.Uint64() != 100 {
+		t.Errorf("expected chain id to be 100, got: %v (%v)", chainID, err)
+	}
+}
@@ -0,0 +1,100 @@
+package erc1271
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// OverrideAccount describes the state override of the account applied to eth_call, State replaces the whole storage
+// while StateDiff only replaces the listed slots
+type OverrideAccount struct {
+	Nonce     *uint64
+	Code      []byte
+	Balance   *big.Int
+	State     map[common.Hash]common.Hash
+	StateDiff map[common.Hash]common.Hash
+}
+
+// overrideAccountJSON is JSON-RPC representation of OverrideAccount
+type overrideAccountJSON struct {
+	Nonce     *hexutil.Uint64             `json:"nonce,omitempty"`
+	Code      *hexutil.Bytes              `json:"code,omitempty"`
+	Balance   *hexutil.Big                `json:"balance,omitempty"`
+	State     map[common.Hash]common.Hash `json:"state,omitempty"`
+	StateDiff map[common.Hash]common.Hash `json:"stateDiff,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (a OverrideAccount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(overrideAccountJSON{
+		Nonce:     (*hexutil.Uint64)(a.Nonce),
+		Code:      (*hexutil.Bytes)(codeOrNil(a.Code)),
+		Balance:   (*hexutil.Big)(a.Balance),
+		State:     a.State,
+		StateDiff: a.StateDiff,
+	})
+}
+
+// codeOrNil returns pointer to the code, nil if the code is not overridden
+func codeOrNil(code []byte) *[]byte {
+	if code == nil {
+		return nil
+	}
+
+	return &code
+}
+
+// ValidateWithOverrides validates the request with eth_call state overrides applied (i.e. "would this signature be
+// valid if the owner was X"), requires raw JSON-RPC client to be set with WithRPCClient
+//
+// Overridden code of validator address is used by CodeAt check as well, so counterfactual wallets can be simulated by
+// injecting their runtime code. Result, code and ERC-165 caches are bypassed
+func (v *Validator) ValidateWithOverrides(ctx context.Context, request ValidationRequest, overrides map[common.Address]OverrideAccount) (*ValidationResult, error) {
+	if v.rpcClient == nil {
+		return nil, errors.New("state overrides require raw rpc client")
+	}
+
+	c := v.clone()
+	c.client = &overrideCaller{client: v.stateCaller(), rpcClient: v.rpcClient, overrides: overrides, block: v.blockArg}
+	c.codeCache, c.resultCache = nil, nil
+	if c.erc165Cache != nil {
+		c.erc165Cache = newLRUCache(DefaultERC165CacheSize)
+	}
+
+	return c.validateHash(ctx, request.Hash, request.data(), request.Signer, request.Signature)
+}
+
+// overrideCaller is bind.ContractCaller applying state overrides to eth_call and CodeAt
+type overrideCaller struct {
+	client    bind.ContractCaller
+	rpcClient RPCCaller
+	overrides map[common.Address]OverrideAccount
+	// block converts the block number into JSON-RPC block argument
+	block func(blockNumber *big.Int) interface{}
+}
+
+// CodeAt implements bind.ContractCaller, returns overridden code if set
+func (c *overrideCaller) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	if override, ok := c.overrides[contract]; ok && override.Code != nil {
+		return override.Code, nil
+	}
+
+	return c.client.CodeAt(ctx, contract, blockNumber)
+}
+
+// CallContract implements bind.ContractCaller, performs raw eth_call with state overrides
+func (c *overrideCaller) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	var result hexutil.Bytes
+	if err := c.rpcClient.CallContext(ctx, &result, "eth_call", toCallArg(msg), c.block(blockNumber), c.overrides); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,114 @@
+package erc1271
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// mockOverrideRPC is an in-memory RPCCaller answering eth_call as a wallet accepting the hash stored at slot 0 of the
+// state override (the wallet only exists if its code is overridden)
+type mockOverrideRPC struct {
+	wallet common.Address
+}
+
+func (m *mockOverrideRPC) CallContext(_ context.Context, result interface{}, method string, args ...interface{}) error {
+	if method != "eth_call" {
+		return errors.New("the method does not exist")
+	}
+
+	raw, err := json.Marshal(args[2])
+	if err != nil {
+		return err
+	}
+
+	var overrides map[common.Address]struct {
+		Code      hexutil.Bytes               `json:"code"`
+		StateDiff map[common.Hash]common.Hash `json:"stateDiff"`
+	}
+	if err := json.Unmarshal(raw, &overrides); err != nil {
+		return err
+	}
+
+	call := args[0].(map[string]interface{})
+	data := call["data"].(hexutil.Bytes)
+	override, ok := overrides[m.wallet]
+	if !ok || len(override.Code) == 0 || override.StateDiff[common.Hash{}] != common.BytesToHash(data[4:36]) {
+		return &revertError{}
+	}
+
+	*(result.(*hexutil.Bytes)) = common.RightPadBytes(ValidSignature, 32)
+	return nil
+}
+
+func TestValidateWithOverrides(t *testing.T) {
+	ctx := context.Background()
+	hash := crypto.Keccak256Hash([]byte("Hello go test!"))
+	wallet := common.HexToAddress("0x607377F587B1BDc68Bec3E19316D56bA8929d5eB")
+	request := ValidationRequest{Hash: hash, Signer: wallet, Signature: []byte{0x01}}
+
+	// the wallet is not deployed
+	validator := NewValidator(&mockCaller{}).WithRPCClient(&mockOverrideRPC{wallet: wallet})
+
+	type Case struct {
+		Description string
+		Overrides   map[common.Address]OverrideAccount
+		Valid       bool
+		Status      ValidationStatus
+	}
+
+	tests := []Case{
+		{
+			Description: "No overrides",
+			Overrides:   nil,
+			Valid:       false,
+			Status:      ValidationStatusNotContract,
+		},
+		{
+			Description: "Injected code and owner",
+			Overrides: map[common.Address]OverrideAccount{
+				wallet: {Code: []byte{0x60, 0x80}, StateDiff: map[common.Hash]common.Hash{{}: hash}},
+			},
+			Valid:  true,
+			Status: ValidationStatusValid,
+		},
+		{
+			Description: "Injected code and different owner",
+			Overrides: map[common.Address]OverrideAccount{
+				wallet: {Code: []byte{0x60, 0x80}, StateDiff: map[common.Hash]common.Hash{{}: common.HexToHash("0x01")}},
+			},
+			Valid:  false,
+			Status: ValidationStatusReverted,
+		},
+	}
+
+	for i, test := range tests {
+		res, err := validator.ValidateWithOverrides(ctx, request, test.Overrides)
+		if err != nil {
+			t.Errorf("%d (%s): expected err to be nil, got: %s", i, test.Description, err)
+			continue
+		}
+
+		if res.Valid != test.Valid || res.Status != test.Status {
+			t.Errorf("%d (%s): expected result to be %t (%s), got: %t (%s)", i, test.Description, test.Valid, test.Status, res.Valid, res.Status)
+		}
+	}
+
+	// ERC-165 outcome of the overridden code must not be cached for the real state
+	erc165Validator := NewValidator(&mockCaller{}).WithRPCClient(&mockOverrideRPC{wallet: wallet}).WithERC165Check(ERC165PolicyAttempt)
+	if _, err := erc165Validator.ValidateWithOverrides(ctx, request, tests[1].Overrides); err != nil {
+		t.Fatal(err)
+	}
+	if n := erc165Validator.erc165Cache.len(); n != 0 {
+		t.Errorf("expected erc165 cache to be empty, got %d entries", n)
+	}
+
+	if _, err := NewValidator(&mockCaller{}).ValidateWithOverrides(ctx, request, nil); err == nil {
+		t.Errorf("expected err without rpc client, got nil")
+	}
+}
@@ -0,0 +1,454 @@
+package erc1271
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+
+	"github.com/holyheld/gaelogrus"
+)
+
+// DefaultProofMaxRounds is the default maximal number of local executions ProofCaller performs to discover the state
+// accessed by the call
+const DefaultProofMaxRounds = 16
+
+// ErrInvalidProof is returned by ProofCaller when the state returned by the node does not match trusted state root
+var ErrInvalidProof = errors.New("invalid state proof")
+
+// emptyCodeHash is keccak256 of empty code
+var emptyCodeHash = crypto.Keccak256Hash(nil)
+
+// ProofCaller is bind.ContractCaller producing results that only depend on the trusted state root, the node is trusted
+// for nothing but data availability
+//
+// Accounts (eth_getProof), storage slots (eth_getProof) and code (eth_getCode, checked against proven code hash) are
+// verified against the state root, then the call is executed locally in the EVM. Every account and storage slot
+// accessed during the execution is recorded, if any of them was not proven yet it is fetched and the call is executed
+// again, until the execution only touches proven state. eth_createAccessList is used as a hint to reduce the number
+// of rounds (the hint itself is not trusted)
+//
+// The execution has the same fork support as LocalCaller, wallets using Cancun opcodes can not be verified
+type ProofCaller struct {
+	client      RPCCaller
+	stateRoot   common.Hash
+	blockNumber *big.Int
+	chainConfig *params.ChainConfig
+	time        *big.Int
+	baseFee     *big.Int
+	maxRounds   int
+}
+
+// NewProofCaller creates a new ProofCaller instance verifying the state of the block with the number against trusted
+// state root, chain ID defaults to 1 (mainnet)
+func NewProofCaller(client RPCCaller, stateRoot common.Hash, blockNumber *big.Int) *ProofCaller {
+	return &ProofCaller{
+		client:      client,
+		stateRoot:   stateRoot,
+		blockNumber: blockNumber,
+		chainConfig: localChainConfig(big.NewInt(1)),
+		maxRounds:   DefaultProofMaxRounds,
+	}
+}
+
+// NewProofCallerFromHeader creates a new ProofCaller instance out of trusted block header (state root, number,
+// timestamp and base fee are taken from it)
+func NewProofCallerFromHeader(client RPCCaller, header *types.Header) *ProofCaller {
+	return NewProofCaller(client, header.Root, header.Number).WithBlockContext(header.Time, header.BaseFee)
+}
+
+// WithChainID sets chain ID returned by CHAINID opcode (used by EIP-712 domains of the wallets)
+func (c *ProofCaller) WithChainID(chainID *big.Int) *ProofCaller {
+	c.chainConfig = localChainConfig(chainID)
+	return c
+}
+
+// WithChainConfig sets chain config the forks are enabled by
+func (c *ProofCaller) WithChainConfig(chainConfig *params.ChainConfig) *ProofCaller {
+	c.chainConfig = chainConfig
+	return c
+}
+
+// WithBlockContext sets timestamp and base fee of the block
+func (c *ProofCaller) WithBlockContext(timestamp uint64, baseFee *big.Int) *ProofCaller {
+	c.time = new(big.Int).SetUint64(timestamp)
+	c.baseFee = baseFee
+	return c
+}
+
+// WithMaxRounds sets maximal number of local executions performed to discover the state accessed by the call
+func (c *ProofCaller) WithMaxRounds(rounds int) *ProofCaller {
+	c.maxRounds = rounds
+	return c
+}
+
+// ChainID returns configured chain ID
+func (c *ProofCaller) ChainID(_ context.Context) (*big.Int, error) {
+	return c.chainConfig.ChainID, nil
+}
+
+// CodeAt implements bind.ContractCaller, returns the code verified against the state root
+func (c *ProofCaller) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	if err := c.checkBlock(blockNumber); err != nil {
+		return nil, err
+	}
+
+	accounts := provenState{}
+	if err := c.prove(ctx, accounts, accessSet{contract: {}}); err != nil {
+		return nil, err
+	}
+
+	return accounts[contract].code, nil
+}
+
+// CallContract implements bind.ContractCaller, executes the call locally against the state verified against the state
+// root
+func (c *ProofCaller) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	logger := gaelogrus.GetLogger(ctx).WithField("func", "ProofCaller.CallContract")
+	if err := c.checkBlock(blockNumber); err != nil {
+		return nil, err
+	}
+
+	accounts := provenState{}
+	pending := accessSet{msg.From: {}}
+	if msg.To != nil {
+		pending.add(*msg.To, nil)
+	}
+	pending.merge(c.accessListHint(ctx, msg))
+
+	for round := 0; round < c.maxRounds; round++ {
+		if err := c.prove(ctx, accounts, pending); err != nil {
+			return nil, err
+		}
+
+		local, err := NewLocalCaller(accounts.dump())
+		if err != nil {
+			return nil, err
+		}
+		local.chainConfig, local.blockNumber, local.time, local.baseFee = c.chainConfig, c.blockNumber, c.time, c.baseFee
+
+		recorder := newAccessRecorder()
+		out, err := local.call(msg, nil, recorder)
+		pending = accounts.missing(recorder.accessed)
+		if len(pending) == 0 {
+			return out, err
+		}
+
+		logger.WithField("round", round).WithField("accounts", len(pending)).Debug("execution accessed unproven state")
+	}
+
+	return nil, fmt.Errorf("state accessed by the call is not discovered in %d rounds", c.maxRounds)
+}
+
+// checkBlock checks that the request is not pinned to another block
+func (c *ProofCaller) checkBlock(blockNumber *big.Int) error {
+	if blockNumber != nil && blockNumber.Sign() >= 0 && blockNumber.Cmp(c.blockNumber) != 0 {
+		return fmt.Errorf("trusted state root is of block %s, requested block %s", c.blockNumber, blockNumber)
+	}
+
+	return nil
+}
+
+// accessListHint fetches eth_createAccessList of the call, failures are ignored
+func (c *ProofCaller) accessListHint(ctx context.Context, msg ethereum.CallMsg) accessSet {
+	var result struct {
+		AccessList types.AccessList `json:"accessList"`
+	}
+	if err := c.client.CallContext(ctx, &result, "eth_createAccessList", toCallArg(msg), hexutil.EncodeBig(c.blockNumber)); err != nil {
+		gaelogrus.GetLogger(ctx).WithError(err).Debug("failed to create access list")
+		return nil
+	}
+
+	hint := accessSet{}
+	for _, tuple := range result.AccessList {
+		hint.add(tuple.Address, nil)
+		for _, key := range tuple.StorageKeys {
+			hint.add(tuple.Address, &key)
+		}
+	}
+
+	return hint
+}
+
+// proofResponse is the subset of eth_getProof response needed to verify the account and the storage
+type proofResponse struct {
+	AccountProof []hexutil.Bytes `json:"accountProof"`
+	StorageProof []struct {
+		Proof []hexutil.Bytes `json:"proof"`
+	} `json:"storageProof"`
+}
+
+// prove fetches and verifies the accounts and storage slots of the access set, adding them to the proven state
+func (c *ProofCaller) prove(ctx context.Context, accounts provenState, pending accessSet) error {
+	for _, address := range pending.addresses() {
+		account, ok := accounts[address]
+		if !ok {
+			account = &provenAccount{storage: map[common.Hash]common.Hash{}}
+		}
+
+		var slots []common.Hash
+		for slot := range pending[address] {
+			if _, ok := account.storage[slot]; !ok {
+				slots = append(slots, slot)
+			}
+		}
+		sort.Slice(slots, func(i, j int) bool { return bytes.Compare(slots[i][:], slots[j][:]) < 0 })
+
+		keys := make([]string, len(slots))
+		for i, slot := range slots {
+			keys[i] = slot.Hex()
+		}
+
+		var proof proofResponse
+		if err := c.client.CallContext(ctx, &proof, "eth_getProof", address, keys, hexutil.EncodeBig(c.blockNumber)); err != nil {
+			return &RPCError{Method: "eth_getProof", Err: err}
+		}
+
+		if !ok {
+			if err := c.verifyAccount(ctx, address, account, proof.AccountProof); err != nil {
+				return err
+			}
+		}
+
+		if err := verifyStorage(address, account, slots, proof); err != nil {
+			return err
+		}
+
+		accounts[address] = account
+	}
+
+	return nil
+}
+
+// verifyAccount verifies the account proof against the state root and fetches the code
+func (c *ProofCaller) verifyAccount(ctx context.Context, address common.Address, account *provenAccount, proof []hexutil.Bytes) error {
+	value, err := trie.VerifyProof(c.stateRoot, crypto.Keccak256(address.Bytes()), proofDB(proof))
+	if err != nil {
+		return fmt.Errorf("%w: account %s: %s", ErrInvalidProof, address.Hex(), err)
+	}
+
+	account.StateAccount = types.StateAccount{Balance: new(big.Int), Root: types.EmptyRootHash, CodeHash: emptyCodeHash.Bytes()}
+	if value != nil {
+		if err := rlp.DecodeBytes(value, &account.StateAccount); err != nil {
+			return fmt.Errorf("%w: account %s: %s", ErrInvalidProof, address.Hex(), err)
+		}
+	}
+
+	if bytes.Equal(account.CodeHash, emptyCodeHash.Bytes()) {
+		return nil
+	}
+
+	var code hexutil.Bytes
+	if err := c.client.CallContext(ctx, &code, "eth_getCode", address, hexutil.EncodeBig(c.blockNumber)); err != nil {
+		return &RPCError{Method: "eth_getCode", Err: err}
+	}
+
+	if !bytes.Equal(crypto.Keccak256(code), account.CodeHash) {
+		return fmt.Errorf("%w: code of %s does not match code hash", ErrInvalidProof, address.Hex())
+	}
+
+	account.code = code
+	return nil
+}
+
+// verifyStorage verifies the storage proofs against the storage root of the account
+func verifyStorage(address common.Address, account *provenAccount, slots []common.Hash, proof proofResponse) error {
+	if account.Root == types.EmptyRootHash {
+		for _, slot := range slots {
+			account.storage[slot] = common.Hash{}
+		}
+		return nil
+	}
+
+	if len(proof.StorageProof) != len(slots) {
+		return fmt.Errorf("%w: expected %d storage proofs of %s, got: %d", ErrInvalidProof, len(slots), address.Hex(), len(proof.StorageProof))
+	}
+
+	for i, slot := range slots {
+		value, err := trie.VerifyProof(account.Root, crypto.Keccak256(slot.Bytes()), proofDB(proof.StorageProof[i].Proof))
+		if err != nil {
+			return fmt.Errorf("%w: storage %s of %s: %s", ErrInvalidProof, slot.Hex(), address.Hex(), err)
+		}
+
+		var content []byte
+		if value != nil {
+			if _, content, _, err = rlp.Split(value); err != nil {
+				return fmt.Errorf("%w: storage %s of %s: %s", ErrInvalidProof, slot.Hex(), address.Hex(), err)
+			}
+		}
+		account.storage[slot] = common.BytesToHash(content)
+	}
+
+	return nil
+}
+
+// proofDB builds the database of the proof nodes keyed by their hashes
+func proofDB(proof []hexutil.Bytes) *memorydb.Database {
+	db := memorydb.New()
+	for _, node := range proof {
+		_ = db.Put(crypto.Keccak256(node), node)
+	}
+
+	return db
+}
+
+// provenAccount is the account verified against the state root
+type provenAccount struct {
+	types.StateAccount
+	code    []byte
+	storage map[common.Hash]common.Hash
+}
+
+// provenState is the set of accounts verified against the state root
+type provenState map[common.Address]*provenAccount
+
+// dump converts proven state into state dump executed by LocalCaller
+func (s provenState) dump() *state.Dump {
+	dump := &state.Dump{Accounts: make(map[common.Address]state.DumpAccount, len(s))}
+	for address, account := range s {
+		storage := make(map[common.Hash]string, len(account.storage))
+		for slot, value := range account.storage {
+			storage[slot] = value.Hex()
+		}
+
+		dump.Accounts[address] = state.DumpAccount{
+			Balance: account.Balance.String(),
+			Nonce:   account.Nonce,
+			Code:    account.code,
+			Storage: storage,
+		}
+	}
+
+	return dump
+}
+
+// missing returns the accessed accounts and storage slots not proven yet
+func (s provenState) missing(accessed accessSet) accessSet {
+	missing := accessSet{}
+	for address, slots := range accessed {
+		account, ok := s[address]
+		if !ok {
+			missing.add(address, nil)
+		}
+
+		for slot := range slots {
+			if ok {
+				if _, proven := account.storage[slot]; proven {
+					continue
+				}
+			}
+			slot := slot
+			missing.add(address, &slot)
+		}
+	}
+
+	return missing
+}
+
+// accessSet is the set of accounts and their storage slots
+type accessSet map[common.Address]map[common.Hash]struct{}
+
+// add adds the account (and the slot, if not nil) to the set
+func (s accessSet) add(address common.Address, slot *common.Hash) {
+	if _, ok := s[address]; !ok {
+		s[address] = map[common.Hash]struct{}{}
+	}
+
+	if slot != nil {
+		s[address][*slot] = struct{}{}
+	}
+}
+
+// merge adds all the accounts and slots of the other set
+func (s accessSet) merge(other accessSet) {
+	for address, slots := range other {
+		s.add(address, nil)
+		for slot := range slots {
+			s[address][slot] = struct{}{}
+		}
+	}
+}
+
+// addresses returns sorted addresses of the set
+func (s accessSet) addresses() []common.Address {
+	addresses := make([]common.Address, 0, len(s))
+	for address := range s {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool { return bytes.Compare(addresses[i][:], addresses[j][:]) < 0 })
+
+	return addresses
+}
+
+// accessRecorder is vm.EVMLogger recording the accounts and storage slots accessed by the execution
+type accessRecorder struct {
+	accessed accessSet
+}
+
+// newAccessRecorder creates a new accessRecorder instance
+func newAccessRecorder() *accessRecorder {
+	return &accessRecorder{accessed: accessSet{}}
+}
+
+// CaptureTxStart implements vm.EVMLogger
+func (r *accessRecorder) CaptureTxStart(_ uint64) {}
+
+// CaptureTxEnd implements vm.EVMLogger
+func (r *accessRecorder) CaptureTxEnd(_ uint64) {}
+
+// CaptureStart implements vm.EVMLogger
+func (r *accessRecorder) CaptureStart(_ *vm.EVM, from common.Address, to common.Address, _ bool, _ []byte, _ uint64, _ *big.Int) {
+	r.accessed.add(from, nil)
+	r.accessed.add(to, nil)
+}
+
+// CaptureEnd implements vm.EVMLogger
+func (r *accessRecorder) CaptureEnd(_ []byte, _ uint64, _ time.Duration, _ error) {}
+
+// CaptureEnter implements vm.EVMLogger, records called and created accounts
+func (r *accessRecorder) CaptureEnter(_ vm.OpCode, _ common.Address, to common.Address, _ []byte, _ uint64, _ *big.Int) {
+	r.accessed.add(to, nil)
+}
+
+// CaptureExit implements vm.EVMLogger
+func (r *accessRecorder) CaptureExit(_ []byte, _ uint64, _ error) {}
+
+// CaptureState implements vm.EVMLogger, records storage slots and accounts accessed by the opcodes
+func (r *accessRecorder) CaptureState(_ uint64, op vm.OpCode, _, _ uint64, scope *vm.ScopeContext, _ []byte, _ int, _ error) {
+	stack := scope.Stack
+	switch op {
+	case vm.SLOAD, vm.SSTORE:
+		if len(stack.Data()) >= 1 {
+			slot := common.Hash(stack.Back(0).Bytes32())
+			r.accessed.add(scope.Contract.Address(), &slot)
+		}
+	case vm.BALANCE, vm.EXTCODESIZE, vm.EXTCODECOPY, vm.EXTCODEHASH, vm.SELFDESTRUCT:
+		if len(stack.Data()) >= 1 {
+			r.accessed.add(common.Address(stack.Back(0).Bytes20()), nil)
+		}
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		if len(stack.Data()) >= 2 {
+			r.accessed.add(common.Address(stack.Back(1).Bytes20()), nil)
+		}
+	case vm.SELFBALANCE:
+		r.accessed.add(scope.Contract.Address(), nil)
+	}
+}
+
+// CaptureFault implements vm.EVMLogger
+func (r *accessRecorder) CaptureFault(_ uint64, _ vm.OpCode, _, _ uint64, _ *vm.ScopeContext, _ int, _ error) {}
@@ -0,0 +1,124 @@
+package erc1271
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// mockProofRPC is an in-memory RPCCaller answering eth_getProof and eth_getCode out of the state
+type mockProofRPC struct {
+	state *state.StateDB
+}
+
+func (m *mockProofRPC) CallContext(_ context.Context, result interface{}, method string, args ...interface{}) error {
+	switch method {
+	case "eth_getProof":
+		address := args[0].(common.Address)
+		accountProof, err := m.state.GetProof(address)
+		if err != nil {
+			return err
+		}
+
+		response := result.(*proofResponse)
+		for _, node := range accountProof {
+			response.AccountProof = append(response.AccountProof, node)
+		}
+
+		for _, key := range args[1].([]string) {
+			storageProof, err := m.state.GetStorageProof(address, common.HexToHash(key))
+			if err != nil {
+				return err
+			}
+
+			var proof []hexutil.Bytes
+			for _, node := range storageProof {
+				proof = append(proof, node)
+			}
+			response.StorageProof = append(response.StorageProof, struct {
+				Proof []hexutil.Bytes `json:"proof"`
+			}{Proof: proof})
+		}
+		return nil
+	case "eth_getCode":
+		*(result.(*hexutil.Bytes)) = m.state.GetCode(args[0].(common.Address))
+		return nil
+	default:
+		return errors.New("the method does not exist")
+	}
+}
+
+func TestProofCaller(t *testing.T) {
+	ctx := context.Background()
+	expectedHash := crypto.Keccak256Hash([]byte("Hello go test!"))
+	attackerHash := crypto.Keccak256Hash([]byte("Hello attacker!"))
+	wallet := common.HexToAddress("0x607377F587B1BDc68Bec3E19316D56bA8929d5eB")
+
+	// runtime code returning magic value only for the hash stored at slot 0, reverting otherwise
+	walletCode := []byte{0x60, 0x00, 0x54, 0x60, 0x04, 0x35, 0x14, 0x60, 0x0f, 0x57, 0x60, 0x00, 0x60, 0x00, 0xfd, 0x5b, 0x7f}
+	walletCode = append(walletCode, common.RightPadBytes(ValidSignature, 32)...)
+	walletCode = append(walletCode, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3)
+
+	newState := func(owner common.Hash) (*state.StateDB, common.Hash) {
+		db := state.NewDatabase(rawdb.NewMemoryDatabase())
+		statedb, err := state.New(common.Hash{}, db, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := int64(1); i <= 32; i++ {
+			statedb.SetBalance(common.BigToAddress(big.NewInt(i)), big.NewInt(i))
+		}
+		statedb.SetCode(wallet, walletCode)
+		statedb.SetState(wallet, common.Hash{}, owner)
+		statedb.SetState(wallet, common.HexToHash("0x01"), common.HexToHash("0x01"))
+
+		root, err := statedb.Commit(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		statedb, err = state.New(root, db, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return statedb, root
+	}
+
+	honestState, root := newState(expectedHash)
+	attackerState, _ := newState(attackerHash)
+
+	type Case struct {
+		Description string
+		State       *state.StateDB
+		Hash        common.Hash
+		Valid       bool
+		Err         error
+	}
+
+	tests := []Case{
+		{"Valid signature", honestState, expectedHash, true, nil},
+		{"Invalid signature", honestState, attackerHash, false, nil},
+		{"Tampered state", attackerState, attackerHash, false, ErrInvalidProof},
+	}
+
+	for i, test := range tests {
+		validator := NewValidator(NewProofCaller(&mockProofRPC{state: test.State}, root, big.NewInt(1)))
+		valid, err := validator.ValidateHashBytes(ctx, test.Hash, wallet, []byte{0x01})
+		if !errors.Is(err, test.Err) {
+			t.Errorf("%d (%s): expected err to be %v, got: %v", i, test.Description, test.Err, err)
+			continue
+		}
+
+		if valid != test.Valid {
+			t.Errorf("%d (%s): expected result to be %t, got: %t", i, test.Description, test.Valid, valid)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package erc1271
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ValidationStatus tells why the signature is (in)valid
+type ValidationStatus int
+
+const (
+	// ValidationStatusUnknown is used when validation did not complete (e.g. RPC error)
+	ValidationStatusUnknown ValidationStatus = iota
+	// ValidationStatusValid is used when the signature is valid
+	ValidationStatusValid
+	// ValidationStatusNotContract is used when validator address has no code
+	ValidationStatusNotContract
+	// ValidationStatusReverted is used when isValidSignature call reverted
+	ValidationStatusReverted
+	// ValidationStatusInvalidMagicValue is used when isValidSignature call returned value other than magic value
+	ValidationStatusInvalidMagicValue
+	// ValidationStatusMalformedSignature is used when the signature could not be decoded
+	ValidationStatusMalformedSignature
+	// ValidationStatusSignerMismatch is used when the signer recovered from ECDSA signature differs from expected one
+	ValidationStatusSignerMismatch
+	// ValidationStatusInterfaceNotSupported is used when validator address does not declare ERC-1271 support with
+	// ERC-165 and ERC165PolicyReject is used
+	ValidationStatusInterfaceNotSupported
+)
+
+// String returns human-readable name of the validation status
+func (s ValidationStatus) String() string {
+	switch s {
+	case ValidationStatusValid:
+		return "valid"
+	case ValidationStatusNotContract:
+		return "not_contract"
+	case ValidationStatusReverted:
+		return "reverted"
+	case ValidationStatusInvalidMagicValue:
+		return "invalid_magic_value"
+	case ValidationStatusMalformedSignature:
+		return "malformed_signature"
+	case ValidationStatusSignerMismatch:
+		return "signer_mismatch"
+	case ValidationStatusInterfaceNotSupported:
+		return "interface_not_supported"
+	default:
+		return "unknown"
+	}
+}
+
+// ValidationResult is a detailed outcome of the signature validation
+type ValidationResult struct {
+	// Valid tells if the signature is valid
+	Valid bool
+	// Status tells why the signature is (in)valid
+	Status ValidationStatus
+	// Method tells which verification path produced the verdict
+	Method VerificationMethod
+	// Digest is the hash passed to isValidSignature(bytes32,bytes) (or recovered from)
+	Digest common.Hash
+	// Signer is the expected signer address
+	Signer common.Address
+	// ValidatorAddress is the address isValidSignature was called on
+	ValidatorAddress common.Address
+	// ReturnValue is the 4 bytes value returned by isValidSignature (nil if the call was not made or reverted, or
+	// if the value returned by counterfactual ERC6492 wallet is not the magic one)
+	ReturnValue []byte
+	// RevertData is the raw revert data of isValidSignature call (if reverted and provided by the node)
+	RevertData []byte
+	// RevertReason is the decoded Error(string) revert reason (if any)
+	RevertReason string
+	// ContractCheckPerformed tells if CodeAt(validatorAddress) check was performed
+	ContractCheckPerformed bool
+	// IsContract tells if validator address has code (only meaningful if ContractCheckPerformed)
+	IsContract bool
+	// AccountKind tells if validator address is EOA, contract or EIP-7702 delegated EOA (only meaningful if
+	// ContractCheckPerformed)
+	AccountKind AccountKind
+	// Delegate is the address delegated EOA delegates to (zero unless AccountKind is AccountKindDelegatedEOA)
+	Delegate common.Address
+	// ERC165CheckPerformed tells if ERC-165 supportsInterface check was performed
+	ERC165CheckPerformed bool
+	// SupportsERC1271 tells if validator address declares ERC-1271 support (only meaningful if ERC165CheckPerformed)
+	SupportsERC1271 bool
+	// BlockNumber is the block the state was queried at, nil means latest (not pinned), -1 means pending
+	BlockNumber *big.Int
+	// BlockHash is the hash of the block the state was queried at (zero if not known)
+	BlockHash common.Hash
+	// SafeMessageHash is the SafeMessage hash the owners sign (zero unless validator address is detected as Safe in
+	// Safe mode)
+	SafeMessageHash common.Hash
+	// Cached tells if the result was served from the result cache
+	Cached bool
+	// Err is the per-request failure of ValidateBatch (always nil for single validations, which return it instead)
+	Err error
+
+	// erc165CodeHash is the code hash ERC-165 check outcome is shared by (zero if the outcome is cached per address)
+	erc165CodeHash common.Hash
+}
+
+// setRevert fills revert related fields out of the contract call error
+func (r *ValidationResult) setRevert(err error) {
+	reverted := newExecutionRevertedError(err)
+	r.Status = ValidationStatusReverted
+	r.RevertData = reverted.Data
+	r.RevertReason = reverted.Reason
+}
+
+// setReturnValue fills return value related fields out of the ABI encoded bytes4 returned by the contract call
+func (r *ValidationResult) setReturnValue(out []byte, magicValue []byte) {
+	if len(out) < 4 {
+		r.ReturnValue = common.CopyBytes(out)
+	} else {
+		r.ReturnValue = common.CopyBytes(out[:4])
+	}
+
+	if len(out) < 32 || !bytes.Equal(r.ReturnValue, magicValue) {
+		r.Status = ValidationStatusInvalidMagicValue
+		return
+	}
+
+	r.Valid = true
+	r.Status = ValidationStatusValid
+}
+
+// RevertData extracts revert data out of the contract call error, returns nil if there is none
+func RevertData(err error) []byte {
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return nil
+	}
+
+	switch data := dataErr.ErrorData().(type) {
+	case string:
+		decoded, err := hexutil.Decode(data)
+		if err != nil {
+			return nil
+		}
+		return decoded
+	case []byte:
+		return data
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,169 @@
+package erc1271
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/holyheld/gaelogrus"
+)
+
+// ResultCacheKey identifies the validation, results are only reused for the same chain, signer, validator address,
+// digest, signature, magic values, block, call options and the policies affecting the verdict
+type ResultCacheKey struct {
+	// ChainID is the decimal chain ID
+	ChainID          string
+	Signer           common.Address
+	ValidatorAddress common.Address
+	Digest           common.Hash
+	// SignatureHash is keccak256 of the signature
+	SignatureHash common.Hash
+	MagicValue    [4]byte
+	// LegacyMagicValue is zero if legacy fallback is disabled
+	LegacyMagicValue [4]byte
+	// Block is the decimal block number, empty means latest (not pinned)
+	Block string
+	// BlockHash is the hash of the block if the validation is pinned with WithBlockHash, so blocks of different forks
+	// at the same height do not share the results
+	BlockHash common.Hash
+	// From is msg.sender of isValidSignature call (the signer unless overridden with WithCallFrom)
+	From common.Address
+	// GasLimit is gas limit of isValidSignature call, zero means node's gas cap
+	GasLimit           uint64
+	SafeMode           SafeMode
+	DelegatedEOAPolicy DelegatedEOAPolicy
+	ERC165Policy       ERC165Policy
+}
+
+// ResultCache caches validation results, used by the Validator to skip the RPC requests entirely
+//
+// Implementations must be safe for concurrent use and must not modify the results
+type ResultCache interface {
+	// Get returns cached result and true, or false if the key is not cached (or expired)
+	Get(key ResultCacheKey) (*ValidationResult, bool)
+	// Set caches the result for the key
+	Set(key ResultCacheKey, res *ValidationResult)
+}
+
+// LRUResultCache is in-memory ResultCache evicting least recently used entries, entries expire after TTL
+type LRUResultCache struct {
+	cache *lruCache
+	ttl   time.Duration
+}
+
+// NewLRUResultCache creates a new LRUResultCache instance holding up to size entries
+func NewLRUResultCache(size int, ttl time.Duration) *LRUResultCache {
+	return &LRUResultCache{
+		cache: newLRUCache(size),
+		ttl:   ttl,
+	}
+}
+
+// Get implements ResultCache
+func (c *LRUResultCache) Get(key ResultCacheKey) (*ValidationResult, bool) {
+	value, ok := c.cache.get(key)
+	if !ok {
+		return nil, false
+	}
+
+	return value.(*ValidationResult), true
+}
+
+// Set implements ResultCache
+func (c *LRUResultCache) Set(key ResultCacheKey, res *ValidationResult) {
+	c.cache.set(key, res, c.ttl)
+}
+
+// Len returns the number of cached entries (including expired ones not evicted yet)
+func (c *LRUResultCache) Len() int {
+	return c.cache.len()
+}
+
+// WithResultCache sets the cache of validation results, repeated validations within the TTL skip the RPC requests
+//
+// Chain ID is part of the key, it is set with WithChainID or fetched from the client once. Validations at the pending
+// block and the ones failed with error are never cached
+func (v *Validator) WithResultCache(cache ResultCache) *Validator {
+	v.resultCache = cache
+	v.resultCacheCounters = &cacheCounters{}
+	return v
+}
+
+// WithResultCacheOnlyValid makes the result cache store only valid results, so invalid signatures (i.e. not yet
+// authorized by the wallet) are checked again on every validation
+func (v *Validator) WithResultCacheOnlyValid(onlyValid bool) *Validator {
+	v.resultCacheOnlyValid = onlyValid
+	return v
+}
+
+// ResultCacheStats returns result cache hit/miss counters
+func (v *Validator) ResultCacheStats() CacheStats {
+	return v.resultCacheCounters.stats()
+}
+
+// resultCacheKey builds the cache key of the validation, returns false if the validation can not be cached
+func (v *Validator) resultCacheKey(ctx context.Context, res *ValidationResult, signature []byte) (ResultCacheKey, bool) {
+	if v.resultCache == nil || (res.BlockNumber != nil && res.BlockNumber.Sign() < 0) {
+		return ResultCacheKey{}, false
+	}
+
+	chainID, err := v.ChainID(ctx)
+	if err != nil {
+		gaelogrus.GetLogger(ctx).WithError(err).Debug("failed to get chain id, result is not cached")
+		return ResultCacheKey{}, false
+	}
+
+	key := ResultCacheKey{
+		ChainID:            chainID.String(),
+		Signer:             res.Signer,
+		ValidatorAddress:   res.ValidatorAddress,
+		Digest:             res.Digest,
+		SignatureHash:      crypto.Keccak256Hash(signature),
+		From:               v.callSender(res.Signer),
+		GasLimit:           v.gasLimit,
+		SafeMode:           v.safeMode,
+		DelegatedEOAPolicy: v.delegatedEOAPolicy,
+		ERC165Policy:       v.erc165Policy,
+	}
+	copy(key.MagicValue[:], v.sig)
+	if v.legacyFallback {
+		copy(key.LegacyMagicValue[:], v.legacySig)
+	}
+	if res.BlockNumber != nil {
+		key.Block = res.BlockNumber.String()
+	}
+	if v.block.hash != nil {
+		key.BlockHash = res.BlockHash
+	}
+
+	return key, true
+}
+
+// cachedResult returns a copy of the cached result
+func (v *Validator) cachedResult(key ResultCacheKey, cacheable bool) (*ValidationResult, bool) {
+	if !cacheable {
+		return nil, false
+	}
+
+	cached, ok := v.resultCache.Get(key)
+	v.resultCacheCounters.record(ok)
+	if !ok {
+		return nil, false
+	}
+
+	res := *cached
+	res.Cached = true
+	return &res, true
+}
+
+// cacheResult caches a copy of the result
+func (v *Validator) cacheResult(key ResultCacheKey, cacheable bool, res *ValidationResult) {
+	if !cacheable || (v.resultCacheOnlyValid && !res.Valid) {
+		return
+	}
+
+	cached := *res
+	v.resultCache.Set(key, &cached)
+}
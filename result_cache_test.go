@@ -0,0 +1,198 @@
+package erc1271
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestValidatorResultCache(t *testing.T) {
+	ctx := context.Background()
+	hash := crypto.Keccak256Hash([]byte("Hello go test!"))
+	wallet := common.HexToAddress("0x607377F587B1BDc68Bec3E19316D56bA8929d5eB")
+
+	var calls int
+	client := &mockCaller{
+		code: map[common.Address][]byte{wallet: {0x60, 0x80}},
+		call: func(msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+			calls++
+			if msg.Data[len(msg.Data)-32] == 0x01 {
+				return common.RightPadBytes(ValidSignature, 32), nil
+			}
+			return common.RightPadBytes([]byte{0xff, 0xff, 0xff, 0xff}, 32), nil
+		},
+	}
+
+	type Case struct {
+		Description   string
+		Validator     *Validator
+		Signature     []byte
+		Valid         bool
+		ExpectedCalls int
+	}
+
+	tests := []Case{
+		{
+			Description:   "Valid result is cached",
+			Validator:     NewValidator(client).WithChainID(big.NewInt(1)).WithResultCache(NewLRUResultCache(16, time.Minute)),
+			Signature:     []byte{0x01},
+			Valid:         true,
+			ExpectedCalls: 1,
+		},
+		{
+			Description:   "Invalid result is cached",
+			Validator:     NewValidator(client).WithChainID(big.NewInt(1)).WithResultCache(NewLRUResultCache(16, time.Minute)),
+			Signature:     []byte{0x02},
+			Valid:         false,
+			ExpectedCalls: 1,
+		},
+		{
+			Description:   "Invalid result is not cached (only valid)",
+			Validator:     NewValidator(client).WithChainID(big.NewInt(1)).WithResultCache(NewLRUResultCache(16, time.Minute)).WithResultCacheOnlyValid(true),
+			Signature:     []byte{0x02},
+			Valid:         false,
+			ExpectedCalls: 3,
+		},
+		{
+			Description:   "Pending block result is not cached",
+			Validator:     NewValidator(client).WithChainID(big.NewInt(1)).WithResultCache(NewLRUResultCache(16, time.Minute)).WithBlockTag(BlockTagPending),
+			Signature:     []byte{0x01},
+			Valid:         true,
+			ExpectedCalls: 3,
+		},
+		{
+			Description:   "Result is not cached without chain id",
+			Validator:     NewValidator(client).WithResultCache(NewLRUResultCache(16, time.Minute)),
+			Signature:     []byte{0x01},
+			Valid:         true,
+			ExpectedCalls: 3,
+		},
+	}
+
+	for i, test := range tests {
+		calls = 0
+		for j := 0; j < 3; j++ {
+			res, err := test.Validator.ValidateHashDetailedBytes(ctx, hash, wallet, test.Signature)
+			if err != nil {
+				t.Fatalf("%d (%s): expected err to be nil, got: %s", i, test.Description, err)
+			}
+
+			if res.Valid != test.Valid {
+				t.Errorf("%d (%s): expected result to be %t, got: %t", i, test.Description, test.Valid, res.Valid)
+			}
+
+			if res.Cached != (j > 0 && test.ExpectedCalls == 1) {
+				t.Errorf("%d (%s): expected cached to be %t, got: %t", i, test.Description, !res.Cached, res.Cached)
+			}
+		}
+
+		if calls != test.ExpectedCalls {
+			t.Errorf("%d (%s): expected %d isValidSignature calls, got: %d", i, test.Description, test.ExpectedCalls, calls)
+		}
+	}
+}
+
+func TestValidatorResultCacheKey(t *testing.T) {
+	ctx := context.Background()
+	hash := crypto.Keccak256Hash([]byte("Hello go test!"))
+	wallet := common.HexToAddress("0x607377F587B1BDc68Bec3E19316D56bA8929d5eB")
+	owner := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	stranger := common.HexToAddress("0x0000000000000000000000000000000000000002")
+
+	// the shared validator only accepts the owner (msg.sender) with enough gas
+	client := &mockCaller{
+		code: map[common.Address][]byte{wallet: {0x60, 0x80}},
+		call: func(msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+			if msg.From != owner || (msg.Gas != 0 && msg.Gas-intrinsicGas(msg.Data) < 50_000) {
+				return common.RightPadBytes([]byte{0xff, 0xff, 0xff, 0xff}, 32), nil
+			}
+			return common.RightPadBytes(ValidSignature, 32), nil
+		},
+	}
+
+	cache := NewLRUResultCache(16, time.Minute)
+	validator := NewValidator(client).WithChainID(big.NewInt(1)).WithResultCache(cache).WithValidatorAddress(wallet)
+
+	type Case struct {
+		Description string
+		Validator   *Validator
+		Signer      common.Address
+		Valid       bool
+	}
+
+	tests := []Case{
+		{"Owner", validator, owner, true},
+		{"Stranger sharing validator address", validator, stranger, false},
+		{"Stranger calling from owner", validator.clone().WithCallFrom(owner), stranger, true},
+		{"Owner with low gas limit", validator.clone().WithGasLimit(30_000), owner, false},
+	}
+
+	for i, test := range tests {
+		res, err := test.Validator.ValidateHashDetailedBytes(ctx, hash, test.Signer, []byte{0x01})
+		if err != nil {
+			t.Fatalf("%d (%s): expected err to be nil, got: %s", i, test.Description, err)
+		}
+
+		if res.Valid != test.Valid || res.Signer != test.Signer {
+			t.Errorf("%d (%s): expected result to be %t (signer %s), got: %t (signer %s)", i, test.Description, test.Valid, test.Signer.Hex(), res.Valid, res.Signer.Hex())
+		}
+	}
+}
+
+func TestValidatorResultCacheBlockHash(t *testing.T) {
+	ctx := context.Background()
+	hash := crypto.Keccak256Hash([]byte("Hello go test!"))
+	wallet := common.HexToAddress("0x607377F587B1BDc68Bec3E19316D56bA8929d5eB")
+
+	// blocks of two forks at the same height, the wallet only accepts the signature on the first one
+	forkA := &types.Header{Number: big.NewInt(100), Difficulty: common.Big0}
+	forkB := &types.Header{Number: big.NewInt(100), Difficulty: common.Big0, Extra: []byte("fork")}
+
+	client := &mockHeaderCaller{
+		byHash:   map[common.Hash]*types.Header{forkA.Hash(): forkA, forkB.Hash(): forkB},
+		byNumber: map[uint64]*types.Header{100: forkA},
+	}
+	client.code = map[common.Address][]byte{wallet: {0x60, 0x80}}
+	client.call = func(msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+		if client.byNumber[100] != forkA {
+			return common.RightPadBytes([]byte{0xff, 0xff, 0xff, 0xff}, 32), nil
+		}
+		return common.RightPadBytes(ValidSignature, 32), nil
+	}
+
+	validator := NewValidator(client).WithChainID(big.NewInt(1)).WithResultCache(NewLRUResultCache(16, time.Minute))
+
+	type Case struct {
+		Description string
+		Block       common.Hash
+		Canonical   *types.Header
+		Valid       bool
+		Err         error
+	}
+
+	tests := []Case{
+		{"Fork A block", forkA.Hash(), forkA, true, nil},
+		{"Fork B block at the same height", forkB.Hash(), forkB, false, nil},
+		{"Cached fork A block reorged out", forkA.Hash(), forkB, false, ErrBlockNotFound},
+	}
+
+	for i, test := range tests {
+		client.byNumber[100] = test.Canonical
+		res, err := validator.AtBlockHash(test.Block).ValidateHashDetailedBytes(ctx, hash, wallet, []byte{0x01})
+		if !errors.Is(err, test.Err) {
+			t.Errorf("%d (%s): expected err to be %v, got: %v", i, test.Description, test.Err, err)
+			continue
+		}
+
+		if err == nil && res.Valid != test.Valid {
+			t.Errorf("%d (%s): expected result to be %t, got: %t", i, test.Description, test.Valid, res.Valid)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package erc1271
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/holyheld/gaelogrus"
+)
+
+// RetryPolicy describes how the Validator retries failed eth_getCode and eth_call requests
+type RetryPolicy struct {
+	// MaxAttempts is the maximal number of attempts (including the first one), 0 and 1 disable retries
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between the retries
+	MaxBackoff time.Duration
+	// Multiplier is the factor the delay grows with on every retry
+	Multiplier float64
+	// Jitter is the fraction of the delay randomized (i.e. 0.2 means ±20%)
+	Jitter float64
+	// IsRetryable tells if the error is transient, IsRetryableError is used if nil
+	IsRetryable func(err error) bool
+}
+
+// DefaultRetryPolicy returns the policy with 3 attempts and exponential backoff starting at 100ms
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// retryableMessages are the messages of transient node and transport failures
+var retryableMessages = []string{
+	"too many requests",
+	"rate limit",
+	"timeout",
+	"timed out",
+	"connection reset",
+	"connection refused",
+	"broken pipe",
+	"eof",
+	"service unavailable",
+	"bad gateway",
+	"header not found",
+}
+
+// IsRetryableError tells if the error is transient (rate limiting, 5xx responses, timeouts, connection failures)
+//
+// EVM execution failures (reverts) and context cancellation are never retryable
+func IsRetryableError(err error) bool {
+	if err == nil || IsExecutionError(err) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == -32005 {
+		// limit exceeded
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, retryableMessage := range retryableMessages {
+		if strings.Contains(message, retryableMessage) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WithRetryPolicy sets the policy eth_getCode and eth_call requests are retried with
+func (v *Validator) WithRetryPolicy(policy RetryPolicy) *Validator {
+	v.retryPolicy = policy
+	return v
+}
+
+// codeAt performs CodeAt request retrying transient failures
+func (v *Validator) codeAt(ctx context.Context, address common.Address, blockNumber *big.Int) ([]byte, error) {
+	var code []byte
+	err := v.retry(ctx, "eth_getCode", func() (err error) {
+		callCtx, cancel := v.callContext(ctx)
+		defer cancel()
+
+		code, err = v.stateCaller().CodeAt(callCtx, address, blockNumber)
+		return err
+	})
+
+	return code, err
+}
+
+// callContract performs CallContract request retrying transient failures
+func (v *Validator) callContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	var out []byte
+	err := v.retry(ctx, "eth_call", func() (err error) {
+		callCtx, cancel := v.callContext(ctx)
+		defer cancel()
+
+		out, err = v.stateCaller().CallContract(callCtx, msg, blockNumber)
+		return err
+	})
+
+	return out, err
+}
+
+// retry calls request until it succeeds, fails with not retryable error, attempts are exhausted or context deadline
+// does not leave time for another attempt, the last error is returned
+func (v *Validator) retry(ctx context.Context, method string, request func() error) error {
+	policy := v.retryPolicy
+	isRetryable := policy.IsRetryable
+	if isRetryable == nil {
+		isRetryable = IsRetryableError
+	}
+
+	backoff := policy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := request()
+		if err == nil || attempt >= policy.MaxAttempts {
+			return err
+		}
+
+		// call timeout is transient unless the caller's context is done as well
+		timedOut := errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil
+		if !timedOut && !isRetryable(err) {
+			return err
+		}
+
+		delay := backoff
+		if policy.Jitter > 0 {
+			delay += time.Duration((rand.Float64()*2 - 1) * policy.Jitter * float64(backoff))
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+
+		gaelogrus.GetLogger(ctx).WithError(err).WithFields(map[string]interface{}{
+			"method":  method,
+			"attempt": attempt,
+			"delay":   delay.String(),
+		}).Debug("retrying request")
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+
+		backoff = time.Duration(float64(backoff) * policy.Multiplier)
+		if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package erc1271
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	type Case struct {
+		Description string
+		Err         error
+		Retryable   bool
+	}
+
+	tests := []Case{
+		{"Nil error", nil, false},
+		{"Too many requests", rpc.HTTPError{StatusCode: 429, Status: "429 Too Many Requests"}, true},
+		{"Bad gateway", rpc.HTTPError{StatusCode: 502, Status: "502 Bad Gateway"}, true},
+		{"Unauthorized", rpc.HTTPError{StatusCode: 401, Status: "401 Unauthorized"}, false},
+		{"Connection refused", errors.New("dial tcp: connection refused"), true},
+		{"Execution reverted", &revertError{}, false},
+		{"Context canceled", context.Canceled, false},
+		{"Unknown error", errors.New("method not found"), false},
+	}
+
+	for i, test := range tests {
+		if retryable := IsRetryableError(test.Err); retryable != test.Retryable {
+			t.Errorf("%d (%s): expected result to be %t, got: %t", i, test.Description, test.Retryable, retryable)
+		}
+	}
+}
+
+func TestValidatorRetryPolicy(t *testing.T) {
+	hash := crypto.Keccak256Hash([]byte("Hello go test!"))
+	wallet := common.HexToAddress("0x607377F587B1BDc68Bec3E19316D56bA8929d5eB")
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond, Multiplier: 2, Jitter: 0.2}
+
+	type Case struct {
+		Description   string
+		Failures      int
+		Err           error
+		Policy        RetryPolicy
+		Timeout       time.Duration
+		Valid         bool
+		ExpectedCalls int
+	}
+
+	tests := []Case{
+		{"No retries by default", 1, errors.New("dial tcp: connection refused"), RetryPolicy{}, 0, false, 1},
+		{"Transient failure is retried", 2, errors.New("dial tcp: connection refused"), policy, 0, true, 3},
+		{"Attempts are exhausted", 3, errors.New("dial tcp: connection refused"), policy, 0, false, 3},
+		{"Revert is not retried", 1, &revertError{}, policy, 0, false, 1},
+		{"Deadline is honoured", 2, errors.New("dial tcp: connection refused"), RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second}, 100 * time.Millisecond, false, 1},
+	}
+
+	for i, test := range tests {
+		calls := 0
+		client := &mockCaller{
+			code: map[common.Address][]byte{wallet: {0x60, 0x80}},
+			call: func(_ ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+				calls++
+				if calls <= test.Failures {
+					return nil, test.Err
+				}
+				return common.RightPadBytes(ValidSignature, 32), nil
+			},
+		}
+
+		ctx := context.Background()
+		if test.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, test.Timeout)
+			defer cancel()
+		}
+
+		valid, _ := NewValidator(client).WithRetryPolicy(test.Policy).ValidateHashBytes(ctx, hash, wallet, []byte{0x01})
+		if valid != test.Valid {
+			t.Errorf("%d (%s): expected result to be %t, got: %t", i, test.Description, test.Valid, valid)
+		}
+
+		if calls != test.ExpectedCalls {
+			t.Errorf("%d (%s): expected %d calls, got: %d", i, test.Description, test.ExpectedCalls, calls)
+		}
+	}
+}
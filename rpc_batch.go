@@ -0,0 +1,236 @@
+package erc1271
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// DefaultBatchWindow is the default time BatchCaller waits for more requests before sending the batch
+const DefaultBatchWindow = 10 * time.Millisecond
+
+// DefaultMaxBatchSize is the default maximal number of requests in a single JSON-RPC batch
+const DefaultMaxBatchSize = 100
+
+// BatchRPCCaller is implemented by *rpc.Client
+type BatchRPCCaller interface {
+	BatchCallContext(ctx context.Context, b []rpc.BatchElem) error
+}
+
+// BatchCaller is bind.ContractCaller coalescing eth_getCode and eth_call requests issued within a short window into a
+// single JSON-RPC batch request, errors are reported per request
+//
+// ValidateBatch issues fallback (per-request) validations concurrently when the Validator is backed by BatchCaller,
+// so they are sent in as few batches as possible
+type BatchCaller struct {
+	client       BatchRPCCaller
+	window       time.Duration
+	maxBatchSize int
+	timeout      time.Duration
+
+	mu      sync.Mutex
+	pending []*batchRequest
+	timer   *time.Timer
+}
+
+// batchRequest is a single request waiting for the batch to be sent
+type batchRequest struct {
+	elem rpc.BatchElem
+	// deadline is the deadline of the caller's context, zero if there is none
+	deadline time.Time
+	done     chan struct{}
+}
+
+// NewBatchCaller creates a new BatchCaller instance
+func NewBatchCaller(client BatchRPCCaller) *BatchCaller {
+	return &BatchCaller{
+		client:       client,
+		window:       DefaultBatchWindow,
+		maxBatchSize: DefaultMaxBatchSize,
+	}
+}
+
+// WithWindow sets the time to wait for more requests before sending the batch
+func (c *BatchCaller) WithWindow(window time.Duration) *BatchCaller {
+	c.window = window
+	return c
+}
+
+// WithMaxBatchSize sets maximal number of requests in a single batch, the batch is sent as soon as it is full
+func (c *BatchCaller) WithMaxBatchSize(size int) *BatchCaller {
+	c.maxBatchSize = size
+	return c
+}
+
+// WithTimeout sets maximal time a single batch may take, zero means the batch is only bounded by the deadlines of the
+// requests
+func (c *BatchCaller) WithTimeout(timeout time.Duration) *BatchCaller {
+	c.timeout = timeout
+	return c
+}
+
+// CodeAt implements bind.ContractCaller
+func (c *BatchCaller) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	var result hexutil.Bytes
+	if err := c.call(ctx, &result, "eth_getCode", contract, toBlockNumArg(blockNumber)); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// CallContract implements bind.ContractCaller
+func (c *BatchCaller) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	var result hexutil.Bytes
+	if err := c.call(ctx, &result, "eth_call", toCallArg(msg), toBlockNumArg(blockNumber)); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ChainID fetches chain ID with eth_chainId request
+func (c *BatchCaller) ChainID(ctx context.Context) (*big.Int, error) {
+	var result hexutil.Big
+	if err := c.call(ctx, &result, "eth_chainId"); err != nil {
+		return nil, err
+	}
+
+	return (*big.Int)(&result), nil
+}
+
+// call enqueues the request and waits for the batch to be sent
+func (c *BatchCaller) call(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	request := &batchRequest{
+		elem: rpc.BatchElem{Method: method, Args: args, Result: result},
+		done: make(chan struct{}),
+	}
+	request.deadline, _ = ctx.Deadline()
+
+	c.mu.Lock()
+	c.pending = append(c.pending, request)
+	switch {
+	case len(c.pending) >= c.maxBatchSize:
+		c.flushLocked()
+	case c.timer == nil:
+		c.timer = time.AfterFunc(c.window, c.flush)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-request.done:
+		return request.elem.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// flush sends pending requests
+func (c *BatchCaller) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushLocked()
+}
+
+// flushLocked sends pending requests in background, c.mu must be held
+func (c *BatchCaller) flushLocked() {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+
+	requests := c.pending
+	c.pending = nil
+	if len(requests) == 0 {
+		return
+	}
+
+	go c.send(requests)
+}
+
+// send sends the requests in a single batch and notifies the callers
+func (c *BatchCaller) send(requests []*batchRequest) {
+	elems := make([]rpc.BatchElem, len(requests))
+	for i, request := range requests {
+		elems[i] = request.elem
+	}
+
+	ctx, cancel := c.batchContext(requests)
+	defer cancel()
+
+	err := c.client.BatchCallContext(ctx, elems)
+	for i, request := range requests {
+		request.elem.Error = elems[i].Error
+		if err != nil {
+			request.elem.Error = err
+		}
+		close(request.done)
+	}
+}
+
+// batchContext returns the context of the batch bounded by the latest deadline of the requests (unbounded if any of
+// them has no deadline) and the batch timeout, so a hung endpoint does not hold the batch forever
+func (c *BatchCaller) batchContext(requests []*batchRequest) (context.Context, context.CancelFunc) {
+	var deadline time.Time
+	for _, request := range requests {
+		if request.deadline.IsZero() {
+			deadline = time.Time{}
+			break
+		}
+		if request.deadline.After(deadline) {
+			deadline = request.deadline
+		}
+	}
+
+	if c.timeout > 0 {
+		if timeout := time.Now().Add(c.timeout); deadline.IsZero() || timeout.Before(deadline) {
+			deadline = timeout
+		}
+	}
+
+	if deadline.IsZero() {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithDeadline(context.Background(), deadline)
+}
+
+// toBlockNumArg converts the block number into JSON-RPC block argument the same way ethclient does
+func toBlockNumArg(number *big.Int) string {
+	if number == nil {
+		return BlockTagLatest
+	}
+
+	if number.Cmp(pendingBlockNumber) == 0 {
+		return BlockTagPending
+	}
+
+	return hexutil.EncodeBig(number)
+}
+
+// toCallArg converts the call message into JSON-RPC call object the same way ethclient does
+func toCallArg(msg ethereum.CallMsg) interface{} {
+	arg := map[string]interface{}{
+		"from": msg.From,
+		"to":   msg.To,
+	}
+	if len(msg.Data) > 0 {
+		arg["data"] = hexutil.Bytes(msg.Data)
+	}
+	if msg.Value != nil {
+		arg["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if msg.Gas != 0 {
+		arg["gas"] = hexutil.Uint64(msg.Gas)
+	}
+	if msg.GasPrice != nil {
+		arg["gasPrice"] = (*hexutil.Big)(msg.GasPrice)
+	}
+
+	return arg
+}
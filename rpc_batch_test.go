@@ -0,0 +1,158 @@
+package erc1271
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// mockBatchRPC is an in-memory BatchRPCCaller answering eth_getCode with code and eth_call with call
+type mockBatchRPC struct {
+	code map[common.Address][]byte
+	call func(to common.Address) ([]byte, error)
+
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func (m *mockBatchRPC) BatchCallContext(_ context.Context, b []rpc.BatchElem) error {
+	methods := make([]string, len(b))
+	for i := range b {
+		methods[i] = b[i].Method
+		result := b[i].Result.(*hexutil.Bytes)
+
+		switch b[i].Method {
+		case "eth_getCode":
+			*result = m.code[b[i].Args[0].(common.Address)]
+		case "eth_call":
+			to := b[i].Args[0].(map[string]interface{})["to"].(*common.Address)
+			*result, b[i].Error = m.call(*to)
+		}
+	}
+
+	m.mu.Lock()
+	m.batches = append(m.batches, methods)
+	m.mu.Unlock()
+	return nil
+}
+
+func TestBatchCaller(t *testing.T) {
+	ctx := context.Background()
+
+	hash := crypto.Keccak256Hash([]byte("Hello go test!"))
+	wallet := common.HexToAddress("0x607377F587B1BDc68Bec3E19316D56bA8929d5eB")
+	revertingWallet := common.HexToAddress("0x8Ba1f109551bD432803012645Ac136ddd64DBA72")
+	eoa := common.HexToAddress("0x0000000000000000000000000000000000000e0a")
+
+	client := &mockBatchRPC{
+		code: map[common.Address][]byte{wallet: {0x60, 0x80}, revertingWallet: {0x60, 0x80}},
+		call: func(to common.Address) ([]byte, error) {
+			if to == revertingWallet {
+				return nil, &revertError{}
+			}
+			return common.RightPadBytes(ValidSignature, 32), nil
+		},
+	}
+	validator := NewValidator(NewBatchCaller(client).WithWindow(50 * time.Millisecond))
+
+	type Case struct {
+		Description string
+		Signer      common.Address
+		Valid       bool
+		Status      ValidationStatus
+	}
+
+	tests := []Case{
+		{"Valid signature", wallet, true, ValidationStatusValid},
+		{"Reverted call", revertingWallet, false, ValidationStatusReverted},
+		{"Not a contract", eoa, false, ValidationStatusNotContract},
+	}
+
+	requests := make([]ValidationRequest, len(tests))
+	for i, test := range tests {
+		requests[i] = ValidationRequest{Hash: hash, Signer: test.Signer, Signature: []byte{0x01}}
+	}
+
+	results := validator.ValidateBatch(ctx, requests)
+	for i, test := range tests {
+		if results[i].Err != nil {
+			t.Errorf("%d (%s): expected err to be nil, got: %s", i, test.Description, results[i].Err)
+			continue
+		}
+
+		if results[i].Valid != test.Valid {
+			t.Errorf("%d (%s): expected result to be %t, got: %t", i, test.Description, test.Valid, results[i].Valid)
+		}
+
+		if results[i].Status != test.Status {
+			t.Errorf("%d (%s): expected status to be %s, got: %s", i, test.Description, test.Status, results[i].Status)
+		}
+	}
+
+	// multicall check, code checks of every validator address, isValidSignature calls of the contracts
+	expectedBatches := []int{1, 3, 2}
+	if len(client.batches) != len(expectedBatches) {
+		t.Fatalf("expected %d batches, got: %v", len(expectedBatches), client.batches)
+	}
+
+	for i, size := range expectedBatches {
+		if len(client.batches[i]) != size {
+			t.Errorf("expected batch %d to contain %d requests, got: %v", i, size, client.batches[i])
+		}
+	}
+}
+
+// hangingBatchRPC is BatchRPCCaller never answering, done is closed once the batch context is done
+type hangingBatchRPC struct {
+	done chan struct{}
+}
+
+func (m *hangingBatchRPC) BatchCallContext(ctx context.Context, _ []rpc.BatchElem) error {
+	<-ctx.Done()
+	close(m.done)
+	return ctx.Err()
+}
+
+func TestBatchCallerDeadline(t *testing.T) {
+	wallet := common.HexToAddress("0x607377F587B1BDc68Bec3E19316D56bA8929d5eB")
+
+	type Case struct {
+		Description string
+		Caller      func(client BatchRPCCaller) *BatchCaller
+		Timeout     time.Duration
+	}
+
+	tests := []Case{
+		{"Request deadline", func(client BatchRPCCaller) *BatchCaller { return NewBatchCaller(client) }, 50 * time.Millisecond},
+		{"Batch timeout", func(client BatchRPCCaller) *BatchCaller {
+			return NewBatchCaller(client).WithTimeout(50 * time.Millisecond)
+		}, 0},
+	}
+
+	for i, test := range tests {
+		client := &hangingBatchRPC{done: make(chan struct{})}
+		caller := test.Caller(client).WithWindow(time.Millisecond)
+
+		ctx, cancel := context.Background(), func() {}
+		if test.Timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, test.Timeout)
+		}
+
+		go func() {
+			_, _ = caller.CodeAt(ctx, wallet, nil)
+		}()
+
+		select {
+		case <-client.done:
+		case <-time.After(5 * time.Second):
+			t.Errorf("%d (%s): expected the batch to be cancelled", i, test.Description)
+		}
+		cancel()
+	}
+}
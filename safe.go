@@ -0,0 +1,424 @@
+package erc1271
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/holyheld/gaelogrus"
+)
+
+// SafeMode tells how signatures of Safe (Gnosis Safe) wallets are verified
+type SafeMode int
+
+const (
+	// SafeModeDisabled disables Safe detection, Safe wallets are validated as any other contract
+	SafeModeDisabled SafeMode = iota
+	// SafeModeContract detects Safe wallets, records SafeMessage hash in the result and calls isValidSignature (the
+	// Safe wraps the hash into SafeMessage itself)
+	SafeModeContract
+	// SafeModeOwners detects Safe wallets and verifies the packed owner signatures over SafeMessage hash locally
+	// against getOwners and getThreshold
+	SafeModeOwners
+)
+
+var (
+	// safeDomainTypeHash is the EIP-712 domain type hash of Safe >= 1.3.0
+	safeDomainTypeHash = crypto.Keccak256Hash([]byte("EIP712Domain(uint256 chainId,address verifyingContract)"))
+	// safeLegacyDomainTypeHash is the EIP-712 domain type hash of Safe < 1.3.0
+	safeLegacyDomainTypeHash = crypto.Keccak256Hash([]byte("EIP712Domain(address verifyingContract)"))
+	// safeMessageTypeHash is the type hash of SafeMessage struct
+	safeMessageTypeHash = crypto.Keccak256Hash([]byte("SafeMessage(bytes message)"))
+	// safeSentinelOwner is the sentinel of Safe owners linked list, never a valid owner
+	safeSentinelOwner = common.HexToAddress("0x0000000000000000000000000000000000000001")
+)
+
+// safeABI is the subset of Safe ABI used for detection and owner signatures verification
+const safeABI = `[{"inputs":[],"name":"VERSION","outputs":[{"internalType":"string","name":"","type":"string"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"getOwners","outputs":[{"internalType":"address[]","name":"","type":"address[]"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"getThreshold","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"domainSeparator","outputs":[{"internalType":"bytes32","name":"","type":"bytes32"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"","type":"address"},{"internalType":"bytes32","name":"","type":"bytes32"}],"name":"approvedHashes","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"bytes32","name":"","type":"bytes32"}],"name":"signedMessages","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"masterCopy","outputs":[{"internalType":"address","name":"","type":"address"}],"stateMutability":"view","type":"function"}]`
+
+// safe is parsed safeABI
+var safe = mustABI(safeABI)
+
+// SafeInfo describes detected Safe wallet
+type SafeInfo struct {
+	Address common.Address
+	// Singleton is the implementation the proxy delegates to (zero if it could not be resolved)
+	Singleton common.Address
+	// Version is the value returned by VERSION(), e.g. "1.3.0"
+	Version         string
+	DomainSeparator common.Hash
+	Owners          []common.Address
+	Threshold       uint64
+}
+
+// MessageHash returns SafeMessage hash of the 32 bytes hash, the hash the owners sign for isValidSignature(bytes32,bytes)
+func (s *SafeInfo) MessageHash(hash common.Hash) common.Hash {
+	return SafeMessageHash(s.DomainSeparator, hash.Bytes())
+}
+
+// SafeDomainSeparator computes EIP-712 domain separator of the Safe, versions before 1.3.0 do not include chain ID
+func SafeDomainSeparator(version string, chainID *big.Int, safe common.Address) common.Hash {
+	if !safeVersionAtLeast(version, 1, 3) {
+		return crypto.Keccak256Hash(safeLegacyDomainTypeHash.Bytes(), common.LeftPadBytes(safe.Bytes(), 32))
+	}
+
+	return crypto.Keccak256Hash(
+		safeDomainTypeHash.Bytes(),
+		common.LeftPadBytes(chainID.Bytes(), 32),
+		common.LeftPadBytes(safe.Bytes(), 32),
+	)
+}
+
+// SafeMessageHash computes EIP-712 hash of SafeMessage{message} for the Safe domain, message is the 32 bytes hash for
+// isValidSignature(bytes32,bytes) and raw data for legacy isValidSignature(bytes,bytes)
+func SafeMessageHash(domainSeparator common.Hash, message []byte) common.Hash {
+	return crypto.Keccak256Hash(safeMessageData(domainSeparator, message))
+}
+
+// safeMessageData returns EIP-712 encoded SafeMessage (the preimage of SafeMessageHash)
+func safeMessageData(domainSeparator common.Hash, message []byte) []byte {
+	structHash := crypto.Keccak256Hash(safeMessageTypeHash.Bytes(), crypto.Keccak256(message))
+	return append(append([]byte{0x19, 0x01}, domainSeparator.Bytes()...), structHash.Bytes()...)
+}
+
+// safeVersionAtLeast tells if Safe version (e.g. "1.3.0+L2") is at least major.minor
+func safeVersionAtLeast(version string, major int, minor int) bool {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+
+	versionMajor, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+
+	versionMinor, err := strconv.Atoi(strings.TrimRightFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' }))
+	if err != nil {
+		return false
+	}
+
+	return versionMajor > major || (versionMajor == major && versionMinor >= minor)
+}
+
+// WithSafeMode enables Safe wallets detection (VERSION, getOwners and getThreshold calls) and sets how their
+// signatures are verified
+func (v *Validator) WithSafeMode(mode SafeMode) *Validator {
+	v.safeMode = mode
+	return v
+}
+
+// SafeInfo detects Safe wallet at the address, returns ErrNotSafe if the contract does not behave like a Safe
+func (v *Validator) SafeInfo(ctx context.Context, address common.Address) (*SafeInfo, error) {
+	if v.err != nil {
+		return nil, v.err
+	}
+
+	blockNumber, _, err := v.resolveBlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.safeInfo(ctx, address, blockNumber)
+}
+
+// safeInfo detects Safe wallet at the address and the block
+func (v *Validator) safeInfo(ctx context.Context, address common.Address, blockNumber *big.Int) (*SafeInfo, error) {
+	info := &SafeInfo{Address: address}
+	out, err := v.safeCall(ctx, address, blockNumber, "VERSION")
+	if err != nil {
+		return nil, err
+	}
+	if err := safe.UnpackIntoInterface(&info.Version, "VERSION", out); err != nil || info.Version == "" {
+		return nil, fmt.Errorf("%w: %s", ErrNotSafe, address.Hex())
+	}
+
+	out, err = v.safeCall(ctx, address, blockNumber, "getOwners")
+	if err != nil {
+		return nil, err
+	}
+	if err := safe.UnpackIntoInterface(&info.Owners, "getOwners", out); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotSafe, address.Hex())
+	}
+
+	out, err = v.safeCall(ctx, address, blockNumber, "getThreshold")
+	if err != nil {
+		return nil, err
+	}
+	threshold := new(big.Int)
+	if err := safe.UnpackIntoInterface(&threshold, "getThreshold", out); err != nil || !threshold.IsUint64() {
+		return nil, fmt.Errorf("%w: %s", ErrNotSafe, address.Hex())
+	}
+	info.Threshold = threshold.Uint64()
+
+	if info.DomainSeparator, err = v.safeDomainSeparator(ctx, info, blockNumber); err != nil {
+		return nil, err
+	}
+
+	if info.Singleton, err = v.safeSingleton(ctx, address, blockNumber); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// safeDomainSeparator calls domainSeparator(), computes it out of the version and chain ID if the call fails
+func (v *Validator) safeDomainSeparator(ctx context.Context, info *SafeInfo, blockNumber *big.Int) (common.Hash, error) {
+	out, err := v.safeCall(ctx, info.Address, blockNumber, "domainSeparator")
+	if err != nil && !errors.Is(err, ErrNotSafe) {
+		return common.Hash{}, err
+	}
+	if err == nil && len(out) == 32 {
+		return common.BytesToHash(out), nil
+	}
+
+	chainID, err := v.ChainID(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return SafeDomainSeparator(info.Version, chainID, info.Address), nil
+}
+
+// safeSingleton reads the singleton out of proxy storage slot 0 (if storage can be read) or masterCopy() call, zero
+// address is returned if neither works
+func (v *Validator) safeSingleton(ctx context.Context, address common.Address, blockNumber *big.Int) (common.Address, error) {
+	slot, err := v.storageAt(ctx, address, common.Hash{}, blockNumber)
+	if err == nil {
+		return common.BytesToAddress(slot.Bytes()), nil
+	}
+	if !errors.Is(err, errStorageUnavailable) {
+		return common.Address{}, err
+	}
+
+	out, err := v.safeCall(ctx, address, blockNumber, "masterCopy")
+	if errors.Is(err, ErrNotSafe) || len(out) != 32 {
+		return common.Address{}, nil
+	}
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return common.BytesToAddress(out), nil
+}
+
+// safeCall calls Safe method, execution failures are reported as ErrNotSafe
+func (v *Validator) safeCall(ctx context.Context, address common.Address, blockNumber *big.Int, method string, args ...interface{}) ([]byte, error) {
+	callData, err := safe.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := v.callContract(ctx, ethereum.CallMsg{To: &address, Gas: callGas(v.gasLimit, callData), Data: callData}, blockNumber)
+	if IsExecutionError(err) {
+		return nil, fmt.Errorf("%w: %s: %s", ErrNotSafe, address.Hex(), err)
+	}
+	if err != nil {
+		return nil, &RPCError{Method: "eth_call", Err: err}
+	}
+
+	return out, nil
+}
+
+// verifySafe detects Safe wallet at validator address in Safe mode, returns true if the verdict is produced (owner
+// signatures are verified locally), false if isValidSignature should be called
+func (v *Validator) verifySafe(ctx context.Context, res *ValidationResult, signature []byte) (bool, error) {
+	if v.safeMode == SafeModeDisabled {
+		return false, nil
+	}
+
+	info, err := v.safeInfo(ctx, res.ValidatorAddress, res.BlockNumber)
+	if errors.Is(err, ErrNotSafe) {
+		gaelogrus.GetLogger(ctx).WithField("address", res.ValidatorAddress).WithError(err).Debug("validator address is not a safe")
+		return false, nil
+	}
+	if err != nil {
+		gaelogrus.GetLogger(ctx).WithField("address", res.ValidatorAddress).WithError(err).Warn("failed to detect safe")
+		return false, err
+	}
+
+	res.SafeMessageHash = info.MessageHash(res.Digest)
+	if v.safeMode != SafeModeOwners {
+		return false, nil
+	}
+
+	return true, v.verifySafeOwners(ctx, res, info, signature)
+}
+
+// verifySafeOwners verifies packed owner signatures the same way Safe checkNSignatures does, empty signature is
+// checked against signedMessages (messages signed on-chain)
+func (v *Validator) verifySafeOwners(ctx context.Context, res *ValidationResult, info *SafeInfo, signature []byte) error {
+	logger := gaelogrus.GetLogger(ctx).WithField("func", "verifySafeOwners").WithField("address", res.ValidatorAddress)
+	res.Method = VerificationMethodSafeOwners
+	messageHash := res.SafeMessageHash
+	if len(signature) == 0 {
+		out, err := v.safeCall(ctx, info.Address, res.BlockNumber, "signedMessages", messageHash)
+		if err != nil && !errors.Is(err, ErrNotSafe) {
+			return err
+		}
+
+		res.Valid = err == nil && new(big.Int).SetBytes(out).Sign() != 0
+		res.Status = ValidationStatusValid
+		if !res.Valid {
+			res.Status = ValidationStatusSignerMismatch
+		}
+		return nil
+	}
+
+	if info.Threshold == 0 || info.Threshold > uint64(len(signature))/65 {
+		logger.Debug("not enough owner signatures")
+		res.Status = ValidationStatusMalformedSignature
+		return nil
+	}
+
+	owners := make(map[common.Address]bool, len(info.Owners))
+	for _, owner := range info.Owners {
+		owners[owner] = true
+	}
+
+	var lastOwner common.Address
+	for i := uint64(0); i < info.Threshold; i++ {
+		chunk := signature[i*65 : (i+1)*65]
+		owner, status, err := v.safeSignatureOwner(ctx, res, info, signature, chunk)
+		if err != nil {
+			return err
+		}
+		if status != ValidationStatusValid {
+			logger.WithField("index", i).Debugf("owner signature is not valid: %s", status)
+			res.Status = status
+			return nil
+		}
+
+		if bytes.Compare(owner.Bytes(), lastOwner.Bytes()) <= 0 || owner == safeSentinelOwner || !owners[owner] {
+			logger.WithField("index", i).WithField("owner", owner).Debug("signer is not an owner or owners are not sorted")
+			res.Status = ValidationStatusSignerMismatch
+			return nil
+		}
+		lastOwner = owner
+	}
+
+	res.Valid = true
+	res.Status = ValidationStatusValid
+	return nil
+}
+
+// safeSignatureOwner resolves the owner of 65 bytes signature chunk {r, s, v}, signature type is chosen by v:
+// 0 is contract signature, 1 is approved hash, > 30 is eth_sign and 27/28 is plain ECDSA signature
+func (v *Validator) safeSignatureOwner(ctx context.Context, res *ValidationResult, info *SafeInfo, signature []byte, chunk []byte) (common.Address, ValidationStatus, error) {
+	r, s, sigV := chunk[:32], chunk[32:64], chunk[64]
+	messageHash := res.SafeMessageHash
+	switch {
+	case sigV == 0:
+		owner := common.BytesToAddress(r)
+		contractSignature, ok := safeContractSignature(signature, s, info.Threshold)
+		if !ok {
+			return owner, ValidationStatusMalformedSignature, nil
+		}
+
+		valid, err := v.safeOwnerSignature(ctx, res, info, owner, contractSignature)
+		if err != nil {
+			return owner, ValidationStatusUnknown, err
+		}
+		if !valid {
+			return owner, ValidationStatusSignerMismatch, nil
+		}
+		return owner, ValidationStatusValid, nil
+	case sigV == 1:
+		owner := common.BytesToAddress(r)
+		out, err := v.safeCall(ctx, info.Address, res.BlockNumber, "approvedHashes", owner, messageHash)
+		if err != nil && !errors.Is(err, ErrNotSafe) {
+			return owner, ValidationStatusUnknown, err
+		}
+		if err != nil || new(big.Int).SetBytes(out).Sign() == 0 {
+			return owner, ValidationStatusSignerMismatch, nil
+		}
+		return owner, ValidationStatusValid, nil
+	case sigV > 30:
+		owner, err := safeRecover(common.BytesToHash(accounts.TextHash(messageHash.Bytes())), r, s, sigV-4)
+		if err != nil {
+			return owner, ValidationStatusMalformedSignature, nil
+		}
+		return owner, ValidationStatusValid, nil
+	default:
+		owner, err := safeRecover(messageHash, r, s, sigV)
+		if err != nil {
+			return owner, ValidationStatusMalformedSignature, nil
+		}
+		return owner, ValidationStatusValid, nil
+	}
+}
+
+// safeOwnerSignature calls isValidSignature of the contract owner from the Safe the same way checkNSignatures does:
+// Safe < 1.5.0 calls legacy isValidSignature(bytes,bytes) with EIP-712 encoded SafeMessage, newer versions call
+// isValidSignature(bytes32,bytes) with SafeMessage hash
+func (v *Validator) safeOwnerSignature(ctx context.Context, res *ValidationResult, info *SafeInfo, owner common.Address, contractSignature []byte) (bool, error) {
+	metaData, magicValue := LegacyContractMetaData, LegacyValidSignature
+	args := []interface{}{safeMessageData(info.DomainSeparator, res.Digest.Bytes()), contractSignature}
+	if safeVersionAtLeast(info.Version, 1, 5) {
+		metaData, magicValue = ContractMetaData, ValidSignature
+		args = []interface{}{res.SafeMessageHash, contractSignature}
+	}
+
+	parsed, err := metaData.GetAbi()
+	if err != nil {
+		return false, err
+	}
+
+	callData, err := parsed.Pack("isValidSignature", args...)
+	if err != nil {
+		return false, err
+	}
+
+	out, err := v.callContract(ctx, ethereum.CallMsg{From: info.Address, To: &owner, Gas: callGas(v.gasLimit, callData), Data: callData}, res.BlockNumber)
+	if IsExecutionError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, &RPCError{Method: "eth_call", Err: err}
+	}
+
+	return len(out) >= 32 && bytes.Equal(out[:4], magicValue), nil
+}
+
+// safeContractSignature extracts dynamic part of the contract signature, offset must point past the static part
+//
+// Offset and length are attacker controlled, the bounds are checked without overflowing
+func safeContractSignature(signature []byte, offset []byte, threshold uint64) ([]byte, bool) {
+	size := uint64(len(signature))
+	start := new(big.Int).SetBytes(offset)
+	if !start.IsUint64() || start.Uint64() < threshold*65 || start.Uint64() > size || size-start.Uint64() < 32 {
+		return nil, false
+	}
+
+	dataStart := start.Uint64() + 32
+	length := new(big.Int).SetBytes(signature[start.Uint64():dataStart])
+	if !length.IsUint64() || length.Uint64() > size-dataStart {
+		return nil, false
+	}
+
+	return signature[dataStart : dataStart+length.Uint64()], true
+}
+
+// safeRecover recovers the signer the same way ecrecover precompile does
+func safeRecover(hash common.Hash, r []byte, s []byte, v byte) (common.Address, error) {
+	if v != 27 && v != 28 {
+		return common.Address{}, fmt.Errorf("invalid signature v: %d", v)
+	}
+
+	signature := append(append(common.CopyBytes(r), s...), v-27)
+	pub, err := crypto.SigToPub(hash.Bytes(), signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return crypto.PubkeyToAddress(*pub), nil
+}
@@ -0,0 +1,173 @@
+package erc1271
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestSafe(t *testing.T) {
+	ctx := context.Background()
+	hash := crypto.Keccak256Hash([]byte("Hello go test!"))
+	safeAddress := common.HexToAddress("0x607377F587B1BDc68Bec3E19316D56bA8929d5eB")
+	contractOwner := common.HexToAddress("0x0000000000000000000000000000000000000100")
+	domainSeparator := SafeDomainSeparator("1.3.0", big.NewInt(1), safeAddress)
+	messageHash := SafeMessageHash(domainSeparator, hash.Bytes())
+
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(crypto.PubkeyToAddress(keys[i].PublicKey).Bytes(), crypto.PubkeyToAddress(keys[j].PublicKey).Bytes()) < 0
+	})
+	owners := []common.Address{contractOwner, crypto.PubkeyToAddress(keys[0].PublicKey), crypto.PubkeyToAddress(keys[1].PublicKey)}
+
+	sign := func(key *ecdsa.PrivateKey) []byte {
+		signature, err := crypto.Sign(messageHash.Bytes(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature[64] += 27
+		return signature
+	}
+	ethSign := func(key *ecdsa.PrivateKey) []byte {
+		signature, err := crypto.Sign(accounts.TextHash(messageHash.Bytes()), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature[64] += 31
+		return signature
+	}
+	staticPart := func(owner common.Address, s uint64, v byte) []byte {
+		chunk := append(common.LeftPadBytes(owner.Bytes(), 32), common.LeftPadBytes(new(big.Int).SetUint64(s).Bytes(), 32)...)
+		return append(chunk, v)
+	}
+	concat := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+
+	legacyABI, err := LegacyContractMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownerCallData, err := legacyABI.Pack("isValidSignature", safeMessageData(domainSeparator, hash.Bytes()), []byte{0xaa})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version := "1.3.0"
+	client := &mockCaller{
+		code: map[common.Address][]byte{safeAddress: {0x60, 0x80}, contractOwner: {0x60, 0x80}},
+		call: func(msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+			selector := msg.Data[:4]
+			switch {
+			case bytes.Equal(selector, ValidSignature):
+				// both the safe and the contract owner accept anything with bytes32 variant
+				return common.RightPadBytes(ValidSignature, 32), nil
+			case bytes.Equal(selector, LegacyValidSignature) && *msg.To == contractOwner:
+				// contract owner accepts 0xaa over encoded SafeMessage called by the safe
+				if msg.From != safeAddress || !bytes.Equal(msg.Data, ownerCallData) {
+					return common.RightPadBytes([]byte{0xde, 0xad}, 32), nil
+				}
+				return common.RightPadBytes(LegacyValidSignature, 32), nil
+			case *msg.To != safeAddress:
+				return nil, &revertError{}
+			case bytes.Equal(selector, safe.Methods["VERSION"].ID):
+				return safe.Methods["VERSION"].Outputs.Pack(version)
+			case bytes.Equal(selector, safe.Methods["getOwners"].ID):
+				return safe.Methods["getOwners"].Outputs.Pack(owners)
+			case bytes.Equal(selector, safe.Methods["getThreshold"].ID):
+				return safe.Methods["getThreshold"].Outputs.Pack(big.NewInt(2))
+			case bytes.Equal(selector, safe.Methods["domainSeparator"].ID):
+				return domainSeparator.Bytes(), nil
+			case bytes.Equal(selector, safe.Methods["approvedHashes"].ID):
+				// the first key approved the message hash on-chain
+				if bytes.Equal(msg.Data[4:36], common.LeftPadBytes(owners[1].Bytes(), 32)) && bytes.Equal(msg.Data[36:68], messageHash.Bytes()) {
+					return common.LeftPadBytes([]byte{0x01}, 32), nil
+				}
+				return make([]byte, 32), nil
+			default:
+				return make([]byte, 32), nil
+			}
+		},
+	}
+
+	info, err := NewValidator(client).SafeInfo(ctx, safeAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != "1.3.0" || info.Threshold != 2 || len(info.Owners) != 3 || info.MessageHash(hash) != messageHash {
+		t.Errorf("unexpected safe info: %+v", info)
+	}
+
+	if _, err := NewValidator(client).SafeInfo(ctx, contractOwner); err == nil {
+		t.Errorf("expected err for not a safe, got nil")
+	}
+
+	stranger, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type Case struct {
+		Description string
+		Mode        SafeMode
+		Signature   []byte
+		Valid       bool
+		Status      ValidationStatus
+		Method      VerificationMethod
+	}
+
+	tests := []Case{
+		{"Contract mode", SafeModeContract, []byte{0x01}, true, ValidationStatusValid, VerificationMethodERC1271},
+		{"EOA signatures", SafeModeOwners, concat(sign(keys[0]), sign(keys[1])), true, ValidationStatusValid, VerificationMethodSafeOwners},
+		{"EOA and eth_sign signatures", SafeModeOwners, concat(sign(keys[0]), ethSign(keys[1])), true, ValidationStatusValid, VerificationMethodSafeOwners},
+		{"Unsorted signatures", SafeModeOwners, concat(sign(keys[1]), sign(keys[0])), false, ValidationStatusSignerMismatch, VerificationMethodSafeOwners},
+		{"Not enough signatures", SafeModeOwners, sign(keys[0]), false, ValidationStatusMalformedSignature, VerificationMethodSafeOwners},
+		{"Not an owner", SafeModeOwners, concat(sign(keys[0]), sign(stranger)), false, ValidationStatusSignerMismatch, VerificationMethodSafeOwners},
+		{"Approved hash", SafeModeOwners, concat(staticPart(owners[1], 0, 1), sign(keys[1])), true, ValidationStatusValid, VerificationMethodSafeOwners},
+		{"Not approved hash", SafeModeOwners, concat(sign(keys[0]), staticPart(owners[2], 0, 1)), false, ValidationStatusSignerMismatch, VerificationMethodSafeOwners},
+		{"Contract signature", SafeModeOwners, concat(staticPart(contractOwner, 130, 0), sign(keys[0]), common.LeftPadBytes([]byte{0x01}, 32), []byte{0xaa}), true, ValidationStatusValid, VerificationMethodSafeOwners},
+		{"Contract signature only valid for bytes32 variant", SafeModeOwners, concat(staticPart(contractOwner, 130, 0), sign(keys[0]), common.LeftPadBytes([]byte{0x01}, 32), []byte{0xbb}), false, ValidationStatusSignerMismatch, VerificationMethodSafeOwners},
+		{"Contract signature out of bounds", SafeModeOwners, concat(staticPart(contractOwner, 130, 0), sign(keys[0])), false, ValidationStatusMalformedSignature, VerificationMethodSafeOwners},
+		{"Contract signature offset overflow", SafeModeOwners, concat(staticPart(contractOwner, 0xfffffffffffffff0, 0), sign(keys[0])), false, ValidationStatusMalformedSignature, VerificationMethodSafeOwners},
+		{"Message not signed on-chain", SafeModeOwners, nil, false, ValidationStatusSignerMismatch, VerificationMethodSafeOwners},
+	}
+
+	for i, test := range tests {
+		res, err := NewValidator(client).WithSafeMode(test.Mode).ValidateHashDetailedBytes(ctx, hash, safeAddress, test.Signature)
+		if err != nil {
+			t.Errorf("%d (%s): expected err to be nil, got: %s", i, test.Description, err)
+			continue
+		}
+
+		if res.Valid != test.Valid || res.Status != test.Status || res.Method != test.Method {
+			t.Errorf("%d (%s): expected result to be %t (%s, %s), got: %t (%s, %s)", i, test.Description, test.Valid, test.Status, test.Method, res.Valid, res.Status, res.Method)
+		}
+
+		if res.SafeMessageHash != messageHash {
+			t.Errorf("%d (%s): expected safe message hash to be %s, got: %s", i, test.Description, messageHash, res.SafeMessageHash)
+		}
+	}
+
+	// Safe 1.5.0 checks contract signatures with bytes32 variant
+	version = "1.5.0"
+	signature := concat(staticPart(contractOwner, 130, 0), sign(keys[0]), common.LeftPadBytes([]byte{0x01}, 32), []byte{0xbb})
+	res, err := NewValidator(client).WithSafeMode(SafeModeOwners).ValidateHashDetailedBytes(ctx, hash, safeAddress, signature)
+	if err != nil || !res.Valid {
+		t.Errorf("expected bytes32 variant contract signature to be valid for safe 1.5.0, got: %v (%v)", res, err)
+	}
+}
@@ -0,0 +1,156 @@
+package erc1271
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+
+	"github.com/holyheld/gaelogrus"
+)
+
+// VerificationMethod tells which verification path produced the verdict
+type VerificationMethod int
+
+const (
+	// VerificationMethodNone is used when verification did not reach any of the paths (e.g. RPC error)
+	VerificationMethodNone VerificationMethod = iota
+	// VerificationMethodERC1271 is used when the verdict is produced by isValidSignature(bytes32,bytes) contract call
+	VerificationMethodERC1271
+	// VerificationMethodECRecover is used when the verdict is produced by recovering the signer from ECDSA signature
+	VerificationMethodECRecover
+	// VerificationMethodERC1271Legacy is used when the verdict is produced by legacy isValidSignature(bytes,bytes)
+	// contract call
+	VerificationMethodERC1271Legacy
+	// VerificationMethodERC6492 is used when the verdict is produced by simulating counterfactual wallet deployment
+	// followed by isValidSignature(bytes32,bytes) call
+	VerificationMethodERC6492
+	// VerificationMethodSafeOwners is used when the verdict is produced by verifying Safe owner signatures over
+	// SafeMessage hash locally
+	VerificationMethodSafeOwners
+)
+
+// String returns human-readable name of the verification method
+func (m VerificationMethod) String() string {
+	switch m {
+	case VerificationMethodERC1271:
+		return "erc1271"
+	case VerificationMethodECRecover:
+		return "ecrecover"
+	case VerificationMethodERC1271Legacy:
+		return "erc1271_legacy"
+	case VerificationMethodERC6492:
+		return "erc6492"
+	case VerificationMethodSafeOwners:
+		return "safe_owners"
+	default:
+		return "none"
+	}
+}
+
+// UniversalVerifier is a helper struct that validates signatures of both EOA and contract-based wallets
+//
+// Uses ERC1271 validation if signer has code, ERC6492 validation if signature is wrapped for counterfactual wallet,
+// falls back to ecrecover otherwise
+type UniversalVerifier struct {
+	validator *Validator
+}
+
+// NewUniversalVerifier creates a new UniversalVerifier instance on top of configured Validator
+func NewUniversalVerifier(validator *Validator) *UniversalVerifier {
+	return &UniversalVerifier{
+		validator: validator,
+	}
+}
+
+// Verify tells if the signature over EIP-191 personal message is valid for the signer and which path produced the
+// verdict
+func (u *UniversalVerifier) Verify(ctx context.Context, message []byte, signer string, signature string) (bool, VerificationMethod, error) {
+	return u.verifyHash(ctx, common.BytesToHash(accounts.TextHash(message)), message, signer, signature)
+}
+
+// VerifyTypedData tells if the signature over EIP-712 typed data is valid for the signer and which path produced the
+// verdict
+func (u *UniversalVerifier) VerifyTypedData(ctx context.Context, typedData apitypes.TypedData, signer string, signature string) (bool, VerificationMethod, error) {
+	hash, err := TypedDataHash(typedData)
+	if err != nil {
+		gaelogrus.GetLogger(ctx).WithField("func", "VerifyTypedData").WithError(err).Debug("failed to hash typed data")
+		return false, VerificationMethodNone, err
+	}
+
+	return u.verifyHash(ctx, hash, hash.Bytes(), signer, signature)
+}
+
+// VerifyHash tells if the signature over already computed 32 bytes hash is valid for the signer and which path
+// produced the verdict
+//
+// Error value should be used to check if the RPC connection is established properly
+func (u *UniversalVerifier) VerifyHash(ctx context.Context, hash common.Hash, signer string, signature string) (bool, VerificationMethod, error) {
+	return u.verifyHash(ctx, hash, hash.Bytes(), signer, signature)
+}
+
+// verifyHash verifies signature over hash, data is passed to legacy isValidSignature(bytes,bytes) call if enabled
+func (u *UniversalVerifier) verifyHash(ctx context.Context, hash common.Hash, data []byte, signer string, signature string) (bool, VerificationMethod, error) {
+	signerAddress, signatureBytes, err := u.validator.parseInput(signer, signature)
+	if err != nil {
+		return false, VerificationMethodNone, err
+	}
+
+	res, err := u.verifyHashDetailed(ctx, hash, data, signerAddress, signatureBytes)
+	if err != nil {
+		return false, VerificationMethodNone, err
+	}
+
+	return res.Valid, res.Method, nil
+}
+
+// verifyHashDetailed verifies signature over hash and returns detailed validation result
+func (u *UniversalVerifier) verifyHashDetailed(ctx context.Context, hash common.Hash, data []byte, signer common.Address, signatureBytes []byte) (*ValidationResult, error) {
+	res, err := u.validator.newResult(ctx, hash, signer)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.verify(ctx, res, data, signatureBytes); err != nil {
+		return nil, err
+	}
+
+	if err := u.validator.checkCanonical(ctx, res.BlockNumber, res.BlockHash); err != nil {
+		return nil, err
+	}
+
+	return res, u.validator.strictError(res)
+}
+
+// verify performs ERC6492, CodeAt and isValidSignature checks, or recovers the signer of EOA, recording the outcome in
+// the result
+func (u *UniversalVerifier) verify(ctx context.Context, res *ValidationResult, data []byte, signatureBytes []byte) error {
+	logger := gaelogrus.GetLogger(ctx).WithField("func", "VerifyHash")
+	if IsERC6492Signature(signatureBytes) {
+		return u.validator.validateERC6492(ctx, res, data, signatureBytes)
+	}
+
+	if err := u.validator.checkContract(ctx, res); err != nil {
+		return err
+	}
+
+	if !res.IsContract {
+		res.Method = VerificationMethodECRecover
+		recovered, err := RecoverSigner(res.Digest, signatureBytes)
+		if err != nil {
+			logger.WithError(err).Debug("failed to recover signer")
+			res.Status = ValidationStatusMalformedSignature
+			return nil
+		}
+
+		res.Valid = recovered == res.Signer
+		res.Status = ValidationStatusValid
+		if !res.Valid {
+			res.Status = ValidationStatusSignerMismatch
+		}
+		return nil
+	}
+
+	return u.validator.contractSignature(ctx, res, data, signatureBytes)
+}
@@ -0,0 +1,87 @@
+package erc1271
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestUniversalVerifier(t *testing.T) {
+	ctx := context.Background()
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	eoa := crypto.PubkeyToAddress(key.PublicKey)
+	wallet := common.HexToAddress("0x607377F587B1BDc68Bec3E19316D56bA8929d5eB")
+	legacyWallet := common.HexToAddress("0xAB833C2DDb1394Cf14AAdCc0aCa4B66Ee84d2C74")
+
+	message := []byte("Hello go test!")
+	hash := accounts.TextHash(message)
+	sig, err := crypto.Sign(hash, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacySig := common.CopyBytes(sig)
+	legacySig[crypto.RecoveryIDOffset] += 27
+	compactSig := common.CopyBytes(sig[:64])
+	compactSig[32] |= sig[crypto.RecoveryIDOffset] << 7
+
+	client := &mockCaller{
+		code: map[common.Address][]byte{wallet: {0x60, 0x80}, legacyWallet: {0x60, 0x80}},
+		call: func(msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+			if *msg.To == legacyWallet {
+				if !bytes.Equal(msg.Data[:4], LegacyValidSignature) {
+					return nil, errors.New("execution reverted")
+				}
+				return common.RightPadBytes(LegacyValidSignature, 32), nil
+			}
+			return common.RightPadBytes(ValidSignature, 32), nil
+		},
+	}
+	verifier := NewUniversalVerifier(NewValidator(client).WithLegacyFallback(true))
+
+	type Case struct {
+		Description string
+		Message     []byte
+		Signer      string
+		Signature   string
+		Valid       bool
+		Method      VerificationMethod
+	}
+
+	tests := []Case{
+		{"Valid EOA signature (v = 0/1)", message, eoa.Hex(), hexutil.Encode(sig), true, VerificationMethodECRecover},
+		{"Valid EOA signature (v = 27/28)", message, eoa.Hex(), hexutil.Encode(legacySig), true, VerificationMethodECRecover},
+		{"Valid EOA signature (EIP-2098 compact)", message, eoa.Hex(), hexutil.Encode(compactSig), true, VerificationMethodECRecover},
+		{"Invalid EOA signature (different message)", []byte("Hello go test!!"), eoa.Hex(), hexutil.Encode(sig), false, VerificationMethodECRecover},
+		{"Invalid EOA signature (malformed)", message, eoa.Hex(), "0xdeadbeef", false, VerificationMethodECRecover},
+		{"Valid ERC1271 signature", message, wallet.Hex(), hexutil.Encode(sig), true, VerificationMethodERC1271},
+		{"Valid legacy ERC1271 signature", message, legacyWallet.Hex(), hexutil.Encode(sig), true, VerificationMethodERC1271Legacy},
+	}
+
+	for i, test := range tests {
+		valid, method, err := verifier.Verify(ctx, test.Message, test.Signer, test.Signature)
+		if err != nil {
+			t.Errorf("%d (%s): expected err to be nil, got: %s", i, test.Description, err)
+			continue
+		}
+
+		if valid != test.Valid {
+			t.Errorf("%d (%s): expected result to be %t, got: %t", i, test.Description, test.Valid, valid)
+		}
+
+		if method != test.Method {
+			t.Errorf("%d (%s): expected method to be %s, got: %s", i, test.Description, test.Method, method)
+		}
+	}
+}
@@ -1,9 +1,15 @@
 package erc1271
 
 import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
 	"reflect"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 )
 
 // IsZeroAddress validates if it's a 0 address
@@ -24,3 +30,111 @@ func IsZeroAddress(iaddress interface{}) bool {
 	addressBytes := address.Bytes()
 	return reflect.DeepEqual(addressBytes, zeroAddressBytes)
 }
+
+// TypedDataHash computes EIP-712 digest of the typed data, i.e. keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message))
+//
+// https://eips.ethereum.org/EIPS/eip-712
+func TypedDataHash(typedData apitypes.TypedData) (common.Hash, error) {
+	hash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return common.BytesToHash(hash), nil
+}
+
+// RecoverSigner recovers the address which produced the signature over the hash
+//
+// Accepts both 65 bytes [R ‖ S ‖ V] signatures with V being either 0/1 or 27/28 and 64 bytes EIP-2098 compact
+// [R ‖ yParityAndS] signatures, rejects malleable (upper range S) signatures
+//
+// https://eips.ethereum.org/EIPS/eip-2098
+func RecoverSigner(hash common.Hash, signature []byte) (common.Address, error) {
+	sig := make([]byte, crypto.SignatureLength)
+	switch len(signature) {
+	case crypto.SignatureLength:
+		copy(sig, signature)
+		if sig[crypto.RecoveryIDOffset] >= 27 {
+			sig[crypto.RecoveryIDOffset] -= 27
+		}
+	case crypto.SignatureLength - 1:
+		copy(sig, signature)
+		sig[crypto.RecoveryIDOffset] = sig[32] >> 7
+		sig[32] &= 0x7f
+	default:
+		return common.Address{}, fmt.Errorf("invalid signature length: %d", len(signature))
+	}
+
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:64])
+	if !crypto.ValidateSignatureValues(sig[crypto.RecoveryIDOffset], r, s, true) {
+		return common.Address{}, errors.New("invalid signature values")
+	}
+
+	pub, err := crypto.SigToPub(hash.Bytes(), sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// ParseAddress parses hex (string) address, unlike common.HexToAddress rejects non-hex and wrong length values and,
+// if requireChecksum is set, values not matching EIP-55 checksum
+//
+// https://eips.ethereum.org/EIPS/eip-55
+func ParseAddress(address string, requireChecksum bool) (common.Address, error) {
+	if !common.IsHexAddress(address) {
+		return common.Address{}, fmt.Errorf("%w: %q", ErrInvalidAddress, address)
+	}
+
+	parsed := common.HexToAddress(address)
+	if requireChecksum && parsed.Hex() != address {
+		return common.Address{}, fmt.Errorf("%w: %q does not match EIP-55 checksum", ErrInvalidAddress, address)
+	}
+
+	return parsed, nil
+}
+
+// ParseSignatureHex parses hex (string) signature, unlike common.FromHex rejects empty, non-hex and odd length values,
+// 0x prefix is optional
+func ParseSignatureHex(signature string) ([]byte, error) {
+	parsed, err := parseHex(signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSignatureHex, err)
+	}
+
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("%w: empty signature", ErrInvalidSignatureHex)
+	}
+
+	return parsed, nil
+}
+
+// ParseMagicValueHex parses hex (string) bytes4 magic value, 0x prefix is optional
+func ParseMagicValueHex(magicValue string) ([]byte, error) {
+	parsed, err := parseHex(magicValue)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMagicValue, err)
+	}
+
+	if len(parsed) != 4 {
+		return nil, fmt.Errorf("%w: expected 4 bytes, got %d", ErrInvalidMagicValue, len(parsed))
+	}
+
+	return parsed, nil
+}
+
+// parseHex strictly decodes hex (string) value with optional 0x prefix
+func parseHex(value string) ([]byte, error) {
+	if has0xPrefix(value) {
+		value = value[2:]
+	}
+
+	return hex.DecodeString(value)
+}
+
+// has0xPrefix checks if the value starts with 0x or 0X
+func has0xPrefix(value string) bool {
+	return len(value) >= 2 && value[0] == '0' && (value[1] == 'x' || value[1] == 'X')
+}
@@ -0,0 +1,121 @@
+package erc1271
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// mailTypedData is the example from EIP-712 specification
+const mailTypedData = `{
+  "types": {
+    "EIP712Domain": [
+      {"name": "name", "type": "string"},
+      {"name": "version", "type": "string"},
+      {"name": "chainId", "type": "uint256"},
+      {"name": "verifyingContract", "type": "address"}
+    ],
+    "Person": [
+      {"name": "name", "type": "string"},
+      {"name": "wallet", "type": "address"}
+    ],
+    "Mail": [
+      {"name": "from", "type": "Person"},
+      {"name": "to", "type": "Person"},
+      {"name": "contents", "type": "string"}
+    ]
+  },
+  "primaryType": "Mail",
+  "domain": {
+    "name": "Ether Mail",
+    "version": "1",
+    "chainId": "1",
+    "verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+  },
+  "message": {
+    "from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+    "to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+    "contents": "Hello, Bob!"
+  }
+}`
+
+func TestTypedDataHash(t *testing.T) {
+	var typedData apitypes.TypedData
+	if err := json.Unmarshal([]byte(mailTypedData), &typedData); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := TypedDataHash(typedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := common.HexToHash("0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2")
+	if hash != expected {
+		t.Errorf("expected hash to be %s, got: %s", expected, hash)
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	type Case struct {
+		Description     string
+		Address         string
+		RequireChecksum bool
+		Valid           bool
+	}
+
+	tests := []Case{
+		{
+			Description: "Checksummed address",
+			Address:     "0x5C6Aa53c883bB6c66CD2A0aD42Ae0828832A40E0",
+			Valid:       true,
+		},
+		{
+			Description: "Lowercase address",
+			Address:     "0x5c6aa53c883bb6c66cd2a0ad42ae0828832a40e0",
+			Valid:       true,
+		},
+		{
+			Description:     "Checksummed address (checksum required)",
+			Address:         "0x5C6Aa53c883bB6c66CD2A0aD42Ae0828832A40E0",
+			RequireChecksum: true,
+			Valid:           true,
+		},
+		{
+			Description:     "Lowercase address (checksum required)",
+			Address:         "0x5c6aa53c883bb6c66cd2a0ad42ae0828832a40e0",
+			RequireChecksum: true,
+			Valid:           false,
+		},
+		{
+			Description:     "Wrong checksum (checksum required)",
+			Address:         "0x5C6AA53c883bB6c66CD2A0aD42Ae0828832A40E0",
+			RequireChecksum: true,
+			Valid:           false,
+		},
+		{
+			Description: "Too short address",
+			Address:     "0x5C6Aa53c883bB6c66CD2A0aD42Ae0828832A40",
+			Valid:       false,
+		},
+		{
+			Description: "Non-hex address",
+			Address:     "0x5C6Aa53c883bB6c66CD2A0aD42Ae0828832A40EZ",
+			Valid:       false,
+		},
+	}
+
+	for i, test := range tests {
+		_, err := ParseAddress(test.Address, test.RequireChecksum)
+		if test.Valid && err != nil {
+			t.Errorf("%d (%s): expected err to be nil, got: %s", i, test.Description, err)
+		}
+
+		if !test.Valid && !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("%d (%s): expected err to be %s, got: %v", i, test.Description, ErrInvalidAddress, err)
+		}
+	}
+}
@@ -1,21 +1,52 @@
 package erc1271
 
 import (
-	"bytes"
 	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 
 	"github.com/holyheld/gaelogrus"
 )
 
 // Validator is a helper struct that provides with convenience method and ERC1271-compliant validate function
 type Validator struct {
-	client              bind.ContractCaller
-	validatorAddress    common.Address
-	sig                 []byte
-	skipIsContractCheck bool
+	client               bind.ContractCaller
+	validatorAddress     common.Address
+	sig                  []byte
+	legacySig            []byte
+	legacyFallback       bool
+	skipIsContractCheck  bool
+	strict               bool
+	requireChecksum      bool
+	rpcClient            RPCCaller
+	block                blockSpec
+	multicallAddress     common.Address
+	batchCallDataLimit   int
+	batchGas             uint64
+	batchCallGas         uint64
+	codeCache            CodeCache
+	codeCacheCounters    *cacheCounters
+	retryPolicy          RetryPolicy
+	gasLimit             uint64
+	callFrom             *common.Address
+	callTimeout          time.Duration
+	resultCache          ResultCache
+	resultCacheCounters  *cacheCounters
+	resultCacheOnlyValid bool
+	chainIDState         *chainIDState
+	safeMode             SafeMode
+	delegatedEOAPolicy   DelegatedEOAPolicy
+	walletRegistry       WalletRegistry
+	erc165Policy         ERC165Policy
+	erc165Cache          *lruCache
+	err                  error
 }
 
 // NewValidator creates a new Validator instance
@@ -23,13 +54,38 @@ func NewValidator(client bind.ContractCaller) *Validator {
 	return &Validator{
 		client:              client,
 		sig:                 ValidSignature,
+		legacySig:           LegacyValidSignature,
 		skipIsContractCheck: false,
+		multicallAddress:    Multicall3Address,
+		batchCallDataLimit:  DefaultBatchCallDataLimit,
+		chainIDState:        &chainIDState{},
+	}
+}
+
+// Err returns the first configuration error (e.g. malformed hex value passed to With...Hex method), validate methods
+// return it as well
+func (v *Validator) Err() error {
+	return v.err
+}
+
+// setErr records the first configuration error
+func (v *Validator) setErr(err error) {
+	if v.err == nil {
+		v.err = err
 	}
 }
 
 // WithCustomValidSignatureHex sets custom valid signature (magic value to compare the results against) using hex (string) value
+//
+// Malformed value (non-hex or not 4 bytes long) is recorded as configuration error, see Err
 func (v *Validator) WithCustomValidSignatureHex(signature string) *Validator {
-	v.sig = common.FromHex(signature)
+	sig, err := ParseMagicValueHex(signature)
+	if err != nil {
+		v.setErr(err)
+		return v
+	}
+
+	v.sig = sig
 	return v
 }
 
@@ -40,8 +96,17 @@ func (v *Validator) WithCustomValidSignature(signature []byte) *Validator {
 }
 
 // WithValidatorAddressHex sets validator address (target contract validator address) using hex (string) value
+//
+// Malformed value (non-hex, wrong length or, if required, not EIP-55 checksummed) is recorded as configuration error,
+// see Err
 func (v *Validator) WithValidatorAddressHex(address string) *Validator {
-	v.validatorAddress = common.HexToAddress(address)
+	validatorAddress, err := ParseAddress(address, v.requireChecksum)
+	if err != nil {
+		v.setErr(err)
+		return v
+	}
+
+	v.validatorAddress = validatorAddress
 	return v
 }
 
@@ -51,6 +116,34 @@ func (v *Validator) WithValidatorAddress(address common.Address) *Validator {
 	return v
 }
 
+// WithLegacyFallback enables legacy isValidSignature(bytes,bytes) call (with raw message bytes as data) when
+// isValidSignature(bytes32,bytes) call reverts or returns non-magic value
+func (v *Validator) WithLegacyFallback(enabled bool) *Validator {
+	v.legacyFallback = enabled
+	return v
+}
+
+// WithCustomLegacyValidSignature sets custom valid signature (magic value to compare the legacy call results against)
+// using byte slice value
+func (v *Validator) WithCustomLegacyValidSignature(signature []byte) *Validator {
+	v.legacySig = signature
+	return v
+}
+
+// WithStrictMode makes validation surface contract related failures as errors (ErrNotContract, ErrExecutionReverted)
+// instead of collapsing them to false
+func (v *Validator) WithStrictMode(strict bool) *Validator {
+	v.strict = strict
+	return v
+}
+
+// WithRequireChecksum makes hex (string) addresses to be rejected unless they are EIP-55 checksummed, must be set
+// before WithValidatorAddressHex to affect it
+func (v *Validator) WithRequireChecksum(require bool) *Validator {
+	v.requireChecksum = require
+	return v
+}
+
 // WithSkipIsContractCheck sets internal skip flag to not perform CodeAt(validatorAddress) check
 func (v *Validator) WithSkipIsContractCheck(skip bool) *Validator {
 	v.skipIsContractCheck = skip
@@ -59,52 +152,378 @@ func (v *Validator) WithSkipIsContractCheck(skip bool) *Validator {
 
 // IsContractHex checks if validatorAddress is smart contract using hex (string) value
 func (v *Validator) IsContractHex(ctx context.Context, validatorAddress string) (bool, error) {
-	return v.IsContract(ctx, common.HexToAddress(validatorAddress))
+	address, err := ParseAddress(validatorAddress, v.requireChecksum)
+	if err != nil {
+		return false, err
+	}
+
+	return v.IsContract(ctx, address)
 }
 
 // IsContract checks if validator address is smart contract using common.Address value
+//
+// EIP-7702 delegated EOAs have code and are reported as contracts, use ClassifyAccount to tell them apart
 func (v *Validator) IsContract(ctx context.Context, validatorAddress common.Address) (bool, error) {
-	code, err := v.client.CodeAt(ctx, validatorAddress, nil)
-	return len(code) > 0, err
+	blockNumber, _, err := v.resolveBlock(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return v.isContractAt(ctx, validatorAddress, blockNumber)
+}
+
+// isContractAt checks if validator address is smart contract (or delegated EOA) at the block number
+func (v *Validator) isContractAt(ctx context.Context, validatorAddress common.Address, blockNumber *big.Int) (bool, error) {
+	kind, _, err := v.accountAt(ctx, validatorAddress, blockNumber)
+	return kind != AccountKindEOA, err
+}
+
+// accountAt classifies the account at the block number
+func (v *Validator) accountAt(ctx context.Context, address common.Address, blockNumber *big.Int) (AccountKind, common.Address, error) {
+	kind, delegate, _, err := v.accountCodeAt(ctx, address, blockNumber)
+	return kind, delegate, err
+}
+
+// accountCodeAt classifies the account at the block number returning its code as well (nil if answered from the code
+// cache), delegated EOAs are never cached since the delegation may be changed by the EOA at any time
+func (v *Validator) accountCodeAt(ctx context.Context, address common.Address, blockNumber *big.Int) (AccountKind, common.Address, []byte, error) {
+	if hasCode, ok := v.cachedHasCode(address, blockNumber); ok {
+		if hasCode {
+			return AccountKindContract, common.Address{}, nil, nil
+		}
+		return AccountKindEOA, common.Address{}, nil, nil
+	}
+
+	code, err := v.codeAt(ctx, address, blockNumber)
+	if err != nil {
+		return AccountKindEOA, common.Address{}, nil, &RPCError{Method: "eth_getCode", Err: err}
+	}
+
+	kind, delegate := classifyCode(code)
+	if kind != AccountKindDelegatedEOA {
+		v.cacheHasCode(address, blockNumber, kind == AccountKindContract)
+	}
+
+	return kind, delegate, code, nil
 }
 
 // Validate performs all the necessary checks to tell if the signature is valid from ERC1271 standpoint
 //
 // Handles obvious contract (response) related errors internally, error value should be used to check if the RPC
-// connection is established properly
+// connection is established properly (errors.Is(err, ErrRPCUnavailable)) or if the input is malformed
+// (ErrInvalidAddress, ErrInvalidSignatureHex). In strict mode contract related failures are returned as errors as well
 func (v *Validator) Validate(ctx context.Context, message []byte, signer string, signature string) (bool, error) {
-	logger := gaelogrus.GetLogger(ctx).WithField("func", "Validate")
-	validatorAddress := common.HexToAddress(signer)
+	return validResult(v.ValidateDetailed(ctx, message, signer, signature))
+}
+
+// ValidateBytes performs the same checks as Validate using typed signer and signature values
+func (v *Validator) ValidateBytes(ctx context.Context, message []byte, signer common.Address, signature []byte) (bool, error) {
+	return validResult(v.ValidateDetailedBytes(ctx, message, signer, signature))
+}
+
+// ValidateTypedData performs the same checks as Validate, but against EIP-712 typed data digest
+// (keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message))) instead of EIP-191 personal message hash
+func (v *Validator) ValidateTypedData(ctx context.Context, typedData apitypes.TypedData, signer string, signature string) (bool, error) {
+	return validResult(v.ValidateTypedDataDetailed(ctx, typedData, signer, signature))
+}
+
+// ValidateTypedDataBytes performs the same checks as ValidateTypedData using typed signer and signature values
+func (v *Validator) ValidateTypedDataBytes(ctx context.Context, typedData apitypes.TypedData, signer common.Address, signature []byte) (bool, error) {
+	return validResult(v.ValidateTypedDataDetailedBytes(ctx, typedData, signer, signature))
+}
+
+// ValidateHash performs the same checks as Validate, but against already computed 32 bytes hash, which is passed to
+// isValidSignature(bytes32,bytes) as is, without any prefixing or hashing
+func (v *Validator) ValidateHash(ctx context.Context, hash common.Hash, signer string, signature string) (bool, error) {
+	return validResult(v.ValidateHashDetailed(ctx, hash, signer, signature))
+}
+
+// ValidateHashBytes performs the same checks as ValidateHash using typed signer and signature values
+func (v *Validator) ValidateHashBytes(ctx context.Context, hash common.Hash, signer common.Address, signature []byte) (bool, error) {
+	return validResult(v.ValidateHashDetailedBytes(ctx, hash, signer, signature))
+}
+
+// ValidateDetailed performs the same checks as Validate, but returns detailed validation result
+//
+// In strict mode the result is returned along with the error describing contract related failure
+func (v *Validator) ValidateDetailed(ctx context.Context, message []byte, signer string, signature string) (*ValidationResult, error) {
+	signerAddress, signatureBytes, err := v.parseInput(signer, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.ValidateDetailedBytes(ctx, message, signerAddress, signatureBytes)
+}
+
+// ValidateDetailedBytes performs the same checks as ValidateDetailed using typed signer and signature values
+func (v *Validator) ValidateDetailedBytes(ctx context.Context, message []byte, signer common.Address, signature []byte) (*ValidationResult, error) {
+	return v.validateHash(ctx, common.BytesToHash(accounts.TextHash(message)), message, signer, signature)
+}
+
+// ValidateTypedDataDetailed performs the same checks as ValidateTypedData, but returns detailed validation result
+func (v *Validator) ValidateTypedDataDetailed(ctx context.Context, typedData apitypes.TypedData, signer string, signature string) (*ValidationResult, error) {
+	signerAddress, signatureBytes, err := v.parseInput(signer, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.ValidateTypedDataDetailedBytes(ctx, typedData, signerAddress, signatureBytes)
+}
+
+// ValidateTypedDataDetailedBytes performs the same checks as ValidateTypedDataDetailed using typed signer and signature
+// values
+func (v *Validator) ValidateTypedDataDetailedBytes(ctx context.Context, typedData apitypes.TypedData, signer common.Address, signature []byte) (*ValidationResult, error) {
+	hash, err := TypedDataHash(typedData)
+	if err != nil {
+		gaelogrus.GetLogger(ctx).WithField("func", "ValidateTypedDataDetailed").WithError(err).Debug("failed to hash typed data")
+		return nil, err
+	}
+
+	return v.validateHash(ctx, hash, hash.Bytes(), signer, signature)
+}
+
+// ValidateHashDetailed performs the same checks as ValidateHash, but returns detailed validation result
+func (v *Validator) ValidateHashDetailed(ctx context.Context, hash common.Hash, signer string, signature string) (*ValidationResult, error) {
+	signerAddress, signatureBytes, err := v.parseInput(signer, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.ValidateHashDetailedBytes(ctx, hash, signerAddress, signatureBytes)
+}
+
+// ValidateHashDetailedBytes performs the same checks as ValidateHashDetailed using typed signer and signature values
+func (v *Validator) ValidateHashDetailedBytes(ctx context.Context, hash common.Hash, signer common.Address, signature []byte) (*ValidationResult, error) {
+	return v.validateHash(ctx, hash, hash.Bytes(), signer, signature)
+}
+
+// validResult collapses detailed validation result into bool
+func validResult(res *ValidationResult, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+
+	return res.Valid, nil
+}
+
+// parseInput parses hex (string) signer and signature values
+func (v *Validator) parseInput(signer string, signature string) (common.Address, []byte, error) {
+	signerAddress, err := ParseAddress(signer, v.requireChecksum)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+
+	signatureBytes, err := ParseSignatureHex(signature)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+
+	return signerAddress, signatureBytes, nil
+}
+
+// newResult creates validation result for the hash and signer, resolving validator address and the block to query
+// the state at
+func (v *Validator) newResult(ctx context.Context, hash common.Hash, signer common.Address) (*ValidationResult, error) {
+	if v.err != nil {
+		return nil, v.err
+	}
+
+	res := &ValidationResult{
+		Digest:           hash,
+		Signer:           signer,
+		ValidatorAddress: signer,
+	}
 	if !IsZeroAddress(v.validatorAddress) {
-		validatorAddress = v.validatorAddress
+		res.ValidatorAddress = v.validatorAddress
+	}
+
+	blockNumber, blockHash, err := v.resolveBlock(ctx)
+	if err != nil {
+		gaelogrus.GetLogger(ctx).WithError(err).Warn("failed to resolve block")
+		return nil, err
+	}
+
+	res.BlockNumber = blockNumber
+	res.BlockHash = blockHash
+	return res, nil
+}
+
+// checkContract performs CodeAt(validatorAddress) check and records it in the result
+func (v *Validator) checkContract(ctx context.Context, res *ValidationResult) error {
+	kind, delegate, code, err := v.accountCodeAt(ctx, res.ValidatorAddress, res.BlockNumber)
+	if err != nil {
+		gaelogrus.GetLogger(ctx).WithField("address", res.ValidatorAddress).WithError(err).Warn("failed to check if validatorAddress is contract")
+		return err
+	}
+
+	res.ContractCheckPerformed = true
+	res.IsContract = kind != AccountKindEOA
+	res.AccountKind = kind
+	res.Delegate = delegate
+	res.erc165CodeHash = erc165CodeHash(code)
+	return nil
+}
+
+// validateHash validates signature over hash, data is passed to legacy isValidSignature(bytes,bytes) call if enabled
+func (v *Validator) validateHash(ctx context.Context, hash common.Hash, data []byte, signer common.Address, signature []byte) (*ValidationResult, error) {
+	res, err := v.newResult(ctx, hash, signer)
+	if err != nil {
+		return nil, err
+	}
+
+	key, cacheable := v.resultCacheKey(ctx, res, signature)
+	if cached, ok := v.cachedResult(key, cacheable); ok {
+		if err := v.checkCanonical(ctx, res.BlockNumber, res.BlockHash); err != nil {
+			return nil, err
+		}
+		return cached, v.strictError(cached)
+	}
+
+	if err := v.verify(ctx, res, data, signature); err != nil {
+		return nil, err
+	}
+
+	if err := v.checkCanonical(ctx, res.BlockNumber, res.BlockHash); err != nil {
+		return nil, err
+	}
+
+	v.cacheResult(key, cacheable, res)
+	return res, v.strictError(res)
+}
+
+// verify performs ERC6492, CodeAt and isValidSignature checks recording the outcome in the result
+func (v *Validator) verify(ctx context.Context, res *ValidationResult, data []byte, signature []byte) error {
+	logger := gaelogrus.GetLogger(ctx).WithField("func", "ValidateHash")
+	if IsERC6492Signature(signature) {
+		return v.validateERC6492(ctx, res, data, signature)
 	}
 
 	if !v.skipIsContractCheck {
-		isContract, err := v.IsContract(ctx, validatorAddress)
-		if err != nil {
-			logger.WithField("address", validatorAddress).WithError(err).Debug("failed to check if validatorAddress is contract")
-			return false, err
+		if err := v.checkContract(ctx, res); err != nil {
+			return err
 		}
 
-		if !isContract {
-			logger.WithField("address", validatorAddress).Debug("specified address is not a contract")
-			return false, nil
+		if !res.IsContract {
+			logger.WithField("address", res.ValidatorAddress).Debug("specified address is not a contract")
+			res.Method = VerificationMethodERC1271
+			res.Status = ValidationStatusNotContract
+			return nil
 		}
 	}
 
-	caller, err := NewContractCaller(validatorAddress, v.client)
+	return v.contractSignature(ctx, res, data, signature)
+}
+
+// contractSignature verifies signature of delegated EOA according to the policy, performs ERC-165 check if enabled,
+// verifies owner signatures of Safe wallet in Safe mode, calls isValidSignature otherwise
+func (v *Validator) contractSignature(ctx context.Context, res *ValidationResult, data []byte, signature []byte) error {
+	if res.AccountKind == AccountKindDelegatedEOA {
+		return v.verifyDelegated(ctx, res, data, signature)
+	}
+
+	if proceed, err := v.checkInterface(ctx, res); err != nil || !proceed {
+		return err
+	}
+
+	if handled, err := v.verifySafe(ctx, res, signature); err != nil || handled {
+		return err
+	}
+
+	return v.isValidSignature(ctx, res, data, signature)
+}
+
+// strictError converts contract related failure recorded in the result into error in strict mode
+func (v *Validator) strictError(res *ValidationResult) error {
+	if !v.strict {
+		return nil
+	}
+
+	switch res.Status {
+	case ValidationStatusNotContract:
+		return fmt.Errorf("%w: %s", ErrNotContract, res.ValidatorAddress.Hex())
+	case ValidationStatusReverted:
+		return &ExecutionRevertedError{Reason: res.RevertReason, Data: res.RevertData}
+	case ValidationStatusInterfaceNotSupported:
+		return fmt.Errorf("%w: %s", ErrInterfaceNotSupported, res.ValidatorAddress.Hex())
+	default:
+		return nil
+	}
+}
+
+// isValidSignature calls isValidSignature(bytes32,bytes) on validator address and compares the result against magic
+// value, falls back to legacy isValidSignature(bytes,bytes) call with data if enabled
+func (v *Validator) isValidSignature(ctx context.Context, res *ValidationResult, data []byte, signature []byte) error {
+	logger := gaelogrus.GetLogger(ctx).WithField("func", "isValidSignature")
+	parsed, err := ContractMetaData.GetAbi()
 	if err != nil {
-		logger.WithError(err).Debug("failed to create contract caller")
-		return false, err
+		logger.WithError(err).Debug("failed to parse contract abi")
+		return err
 	}
 
-	hash := common.BytesToHash(accounts.TextHash(message))
-	signatureHash := common.FromHex(signature)
-	res, err := caller.IsValidSignature(&bind.CallOpts{From: common.HexToAddress(signer)}, hash, signatureHash)
+	res.Method = VerificationMethodERC1271
+	callData, err := parsed.Pack("isValidSignature", res.Digest, signature)
 	if err != nil {
-		logger.WithError(err).Debug("invalid signature")
-		return false, nil
+		logger.WithError(err).Debug("failed to pack isValidSignature call")
+		return err
+	}
+
+	if err := v.callMagicValue(ctx, res, callData, v.sig); err != nil {
+		return err
+	}
+
+	if res.Valid || !v.legacyFallback {
+		return nil
+	}
+
+	legacyParsed, err := LegacyContractMetaData.GetAbi()
+	if err != nil {
+		logger.WithError(err).Debug("failed to parse legacy contract abi")
+		return err
+	}
+
+	callData, err = legacyParsed.Pack("isValidSignature", data, signature)
+	if err != nil {
+		logger.WithError(err).Debug("failed to pack legacy isValidSignature call")
+		return err
+	}
+
+	legacyRes := *res
+	legacyRes.Method = VerificationMethodERC1271Legacy
+	legacyRes.ReturnValue, legacyRes.RevertData, legacyRes.RevertReason = nil, nil, ""
+	if err := v.callMagicValue(ctx, &legacyRes, callData, v.legacySig); err != nil {
+		return err
+	}
+
+	if legacyRes.Valid {
+		*res = legacyRes
+	}
+
+	return nil
+}
+
+// callMagicValue performs the call to validator address and compares the returned bytes4 against magic value
+//
+// Contract related failures are recorded in the result, only RPC failures are returned
+func (v *Validator) callMagicValue(ctx context.Context, res *ValidationResult, callData []byte, magicValue []byte) error {
+	to := res.ValidatorAddress
+	out, err := v.callContract(ctx, ethereum.CallMsg{From: v.callSender(res.Signer), To: &to, Gas: callGas(v.gasLimit, callData), Data: callData}, res.BlockNumber)
+	if err != nil {
+		return v.handleCallError(ctx, res, err)
+	}
+
+	res.setReturnValue(out, magicValue)
+	return nil
+}
+
+// handleCallError records EVM execution failure in the result or returns RPC failure as RPCError
+func (v *Validator) handleCallError(ctx context.Context, res *ValidationResult, err error) error {
+	logger := gaelogrus.GetLogger(ctx).WithField("address", res.ValidatorAddress).WithError(err)
+	if !IsExecutionError(err) {
+		logger.Warn("failed to call isValidSignature")
+		return &RPCError{Method: "eth_call", Err: err}
 	}
 
-	return bytes.Equal(res[:], v.sig), nil
+	logger.Debug("invalid signature")
+	res.setRevert(err)
+	return nil
 }
@@ -1,9 +1,17 @@
 package erc1271
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"math/big"
 	"testing"
 
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
@@ -109,3 +117,373 @@ func TestValidate(t *testing.T) {
 		t.Logf("%d (%s): OK", i, test.Description)
 	}
 }
+
+func TestValidateDetailed(t *testing.T) {
+	ctx := context.Background()
+
+	validWallet := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	revertingWallet := common.HexToAddress("0x0000000000000000000000000000000000000002")
+	invalidWallet := common.HexToAddress("0x0000000000000000000000000000000000000003")
+	eoa := common.HexToAddress("0x0000000000000000000000000000000000000004")
+
+	// Error(string) with "invalid signer" reason
+	revertData := common.FromHex("0x08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000e696e76616c6964207369676e6572000000000000000000000000000000000000")
+
+	client := &mockCaller{
+		code: map[common.Address][]byte{
+			validWallet:     {0x60, 0x80},
+			revertingWallet: {0x60, 0x80},
+			invalidWallet:   {0x60, 0x80},
+		},
+		call: func(msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+			switch *msg.To {
+			case validWallet:
+				return common.RightPadBytes(ValidSignature, 32), nil
+			case revertingWallet:
+				return nil, &revertError{data: revertData}
+			default:
+				return common.RightPadBytes([]byte{0xff, 0xff, 0xff, 0xff}, 32), nil
+			}
+		},
+	}
+
+	type Case struct {
+		Description  string
+		Signer       common.Address
+		Valid        bool
+		Status       ValidationStatus
+		ReturnValue  []byte
+		RevertReason string
+		IsContract   bool
+	}
+
+	tests := []Case{
+		{
+			Description: "Valid signature",
+			Signer:      validWallet,
+			Valid:       true,
+			Status:      ValidationStatusValid,
+			ReturnValue: ValidSignature,
+			IsContract:  true,
+		},
+		{
+			Description:  "Reverted call",
+			Signer:       revertingWallet,
+			Status:       ValidationStatusReverted,
+			RevertReason: "invalid signer",
+			IsContract:   true,
+		},
+		{
+			Description: "Invalid magic value",
+			Signer:      invalidWallet,
+			Status:      ValidationStatusInvalidMagicValue,
+			ReturnValue: []byte{0xff, 0xff, 0xff, 0xff},
+			IsContract:  true,
+		},
+		{
+			Description: "Not a contract",
+			Signer:      eoa,
+			Status:      ValidationStatusNotContract,
+		},
+	}
+
+	for i, test := range tests {
+		res, err := NewValidator(client).ValidateDetailed(ctx, []byte("Hello go test!"), test.Signer.Hex(), "0x01")
+		if err != nil {
+			t.Errorf("%d (%s): expected err to be nil, got: %s", i, test.Description, err)
+			continue
+		}
+
+		if res.Valid != test.Valid || res.Status != test.Status {
+			t.Errorf("%d (%s): expected result to be %t (%s), got: %t (%s)", i, test.Description, test.Valid, test.Status, res.Valid, res.Status)
+		}
+
+		if !bytes.Equal(res.ReturnValue, test.ReturnValue) {
+			t.Errorf("%d (%s): expected return value to be %x, got: %x", i, test.Description, test.ReturnValue, res.ReturnValue)
+		}
+
+		if res.RevertReason != test.RevertReason {
+			t.Errorf("%d (%s): expected revert reason to be %q, got: %q", i, test.Description, test.RevertReason, res.RevertReason)
+		}
+
+		if !res.ContractCheckPerformed || res.IsContract != test.IsContract {
+			t.Errorf("%d (%s): expected contract check to report %t, got: %t", i, test.Description, test.IsContract, res.IsContract)
+		}
+	}
+}
+
+func TestValidateStrict(t *testing.T) {
+	ctx := context.Background()
+
+	revertingWallet := common.HexToAddress("0x0000000000000000000000000000000000000002")
+	unreachableWallet := common.HexToAddress("0x0000000000000000000000000000000000000005")
+	eoa := common.HexToAddress("0x0000000000000000000000000000000000000004")
+
+	client := &mockCaller{
+		code: map[common.Address][]byte{
+			revertingWallet:   {0x60, 0x80},
+			unreachableWallet: {0x60, 0x80},
+		},
+		call: func(msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+			if *msg.To == unreachableWallet {
+				return nil, errors.New("dial tcp: connection refused")
+			}
+			return nil, &revertError{data: common.FromHex("0xdeadbeef")}
+		},
+	}
+
+	type Case struct {
+		Description string
+		Signer      string
+		Signature   string
+		Strict      bool
+		Err         error
+	}
+
+	tests := []Case{
+		{
+			Description: "Reverted call (strict)",
+			Signer:      revertingWallet.Hex(),
+			Signature:   "0x01",
+			Strict:      true,
+			Err:         ErrExecutionReverted,
+		},
+		{
+			Description: "Reverted call",
+			Signer:      revertingWallet.Hex(),
+			Signature:   "0x01",
+		},
+		{
+			Description: "Not a contract (strict)",
+			Signer:      eoa.Hex(),
+			Signature:   "0x01",
+			Strict:      true,
+			Err:         ErrNotContract,
+		},
+		{
+			Description: "RPC failure",
+			Signer:      unreachableWallet.Hex(),
+			Signature:   "0x01",
+			Err:         ErrRPCUnavailable,
+		},
+		{
+			Description: "Invalid signer address",
+			Signer:      "0xnot-an-address",
+			Signature:   "0x01",
+			Err:         ErrInvalidAddress,
+		},
+		{
+			Description: "Invalid signature hex",
+			Signer:      revertingWallet.Hex(),
+			Signature:   "0xzz",
+			Err:         ErrInvalidSignatureHex,
+		},
+		{
+			Description: "Empty signature",
+			Signer:      revertingWallet.Hex(),
+			Signature:   "0x",
+			Err:         ErrInvalidSignatureHex,
+		},
+	}
+
+	for i, test := range tests {
+		valid, err := NewValidator(client).WithStrictMode(test.Strict).Validate(ctx, []byte("Hello go test!"), test.Signer, test.Signature)
+		if valid {
+			t.Errorf("%d (%s): expected result to be false", i, test.Description)
+		}
+
+		if test.Err == nil && err != nil || !errors.Is(err, test.Err) {
+			t.Errorf("%d (%s): expected err to be %v, got: %v", i, test.Description, test.Err, err)
+		}
+	}
+
+	_, err := NewValidator(client).WithStrictMode(true).Validate(ctx, []byte("Hello go test!"), revertingWallet.Hex(), "0x01")
+	var reverted *ExecutionRevertedError
+	if !errors.As(err, &reverted) || !bytes.Equal(reverted.Selector(), common.FromHex("0xdeadbeef")) {
+		t.Errorf("expected err to carry custom error selector 0xdeadbeef, got: %v", err)
+	}
+}
+
+func TestValidateBlockPinning(t *testing.T) {
+	ctx := context.Background()
+
+	wallet := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	finalizedHash := common.HexToHash("0x01")
+
+	var callBlock *big.Int
+	client := &mockCaller{
+		code: map[common.Address][]byte{wallet: {0x60, 0x80}},
+		call: func(msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+			callBlock = blockNumber
+			return common.RightPadBytes(ValidSignature, 32), nil
+		},
+	}
+	rpcClient := &mockRPC{
+		blocks: map[string]rpcBlock{
+			BlockTagFinalized:   {Number: (*hexutil.Big)(big.NewInt(100)), Hash: finalizedHash},
+			finalizedHash.Hex(): {Number: (*hexutil.Big)(big.NewInt(100)), Hash: finalizedHash},
+		},
+	}
+	validator := NewValidator(client).WithRPCClient(rpcClient)
+
+	type Case struct {
+		Description string
+		Validator   *Validator
+		BlockNumber *big.Int
+		Err         bool
+	}
+
+	tests := []Case{
+		{
+			Description: "Not pinned",
+			Validator:   validator,
+		},
+		{
+			Description: "Pinned to block number",
+			Validator:   validator.AtBlockNumber(big.NewInt(42)),
+			BlockNumber: big.NewInt(42),
+		},
+		{
+			Description: "Pinned to pending block",
+			Validator:   validator.AtBlockTag(BlockTagPending),
+			BlockNumber: big.NewInt(-1),
+		},
+		{
+			Description: "Pinned to finalized block",
+			Validator:   validator.AtBlockTag(BlockTagFinalized),
+			BlockNumber: big.NewInt(100),
+		},
+		{
+			Description: "Pinned to unknown block hash",
+			Validator:   validator.AtBlockHash(common.HexToHash("0x02")),
+			Err:         true,
+		},
+		{
+			Description: "Pinned to safe block without rpc client",
+			Validator:   NewValidator(client).AtBlockTag(BlockTagSafe),
+			Err:         true,
+		},
+	}
+
+	for i, test := range tests {
+		client.codeBlocks, callBlock = nil, nil
+		res, err := test.Validator.ValidateDetailed(ctx, []byte("Hello go test!"), wallet.Hex(), "0x01")
+		if test.Err {
+			if err == nil {
+				t.Errorf("%d (%s): expected err to be not nil", i, test.Description)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%d (%s): expected err to be nil, got: %s", i, test.Description, err)
+			continue
+		}
+
+		if len(client.codeBlocks) != 1 || client.codeBlocks[0].String() != fmt.Sprint(test.BlockNumber) || callBlock.String() != fmt.Sprint(test.BlockNumber) || res.BlockNumber.String() != fmt.Sprint(test.BlockNumber) {
+			t.Errorf("%d (%s): expected code check and call to be pinned to %v, got: %v and %v", i, test.Description, test.BlockNumber, client.codeBlocks, callBlock)
+		}
+	}
+}
+
+// mockBlockHashRPC is an in-memory RPCCaller resolving the block hash and recording block arguments of eth_getCode and
+// eth_call requests
+type mockBlockHashRPC struct {
+	block     rpcBlock
+	code      []byte
+	blockArgs map[string]interface{}
+}
+
+func (m *mockBlockHashRPC) CallContext(_ context.Context, result interface{}, method string, args ...interface{}) error {
+	switch method {
+	case "eth_getBlockByHash":
+		*(result.(**rpcBlock)) = &m.block
+	case "eth_getCode":
+		m.blockArgs[method] = args[1]
+		*(result.(*hexutil.Bytes)) = m.code
+	case "eth_call":
+		m.blockArgs[method] = args[1]
+		*(result.(*hexutil.Bytes)) = common.RightPadBytes(ValidSignature, 32)
+	}
+	return nil
+}
+
+// mockHeaderCaller is mockCaller resolving block hashes and numbers with headers
+type mockHeaderCaller struct {
+	mockCaller
+	byHash   map[common.Hash]*types.Header
+	byNumber map[uint64]*types.Header
+}
+
+func (m *mockHeaderCaller) HeaderByHash(_ context.Context, hash common.Hash) (*types.Header, error) {
+	header, ok := m.byHash[hash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return header, nil
+}
+
+func (m *mockHeaderCaller) HeaderByNumber(_ context.Context, number *big.Int) (*types.Header, error) {
+	header, ok := m.byNumber[number.Uint64()]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return header, nil
+}
+
+func TestValidateBlockHashPinning(t *testing.T) {
+	ctx := context.Background()
+	wallet := common.HexToAddress("0x0000000000000000000000000000000000000001")
+
+	pinned := &types.Header{Number: big.NewInt(100), Difficulty: common.Big0}
+	reorged := &types.Header{Number: big.NewInt(100), Difficulty: common.Big0, Extra: []byte("reorg")}
+
+	rpcClient := &mockBlockHashRPC{
+		block:     rpcBlock{Number: (*hexutil.Big)(big.NewInt(100)), Hash: pinned.Hash()},
+		code:      []byte{0x60, 0x80},
+		blockArgs: make(map[string]interface{}),
+	}
+	res, err := NewValidator(&mockCaller{}).WithRPCClient(rpcClient).AtBlockHash(pinned.Hash()).
+		ValidateDetailed(ctx, []byte("Hello go test!"), wallet.Hex(), "0x01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Valid {
+		t.Errorf("expected result to be valid, got: %s", res.Status)
+	}
+	for _, method := range []string{"eth_getCode", "eth_call"} {
+		expected := blockHashArg(pinned.Hash())
+		if fmt.Sprint(rpcClient.blockArgs[method]) != fmt.Sprint(expected) {
+			t.Errorf("expected %s to be pinned with %v, got: %v", method, expected, rpcClient.blockArgs[method])
+		}
+	}
+
+	type Case struct {
+		Description string
+		Canonical   *types.Header
+		Err         error
+	}
+
+	tests := []Case{
+		{"Canonical block", pinned, nil},
+		{"Reorged block", reorged, ErrBlockNotFound},
+	}
+
+	for i, test := range tests {
+		client := &mockHeaderCaller{
+			mockCaller: mockCaller{
+				code: map[common.Address][]byte{wallet: {0x60, 0x80}},
+				call: func(msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+					return common.RightPadBytes(ValidSignature, 32), nil
+				},
+			},
+			byHash:   map[common.Hash]*types.Header{pinned.Hash(): pinned},
+			byNumber: map[uint64]*types.Header{100: test.Canonical},
+		}
+
+		_, err := NewValidator(client).AtBlockHash(pinned.Hash()).ValidateDetailed(ctx, []byte("Hello go test!"), wallet.Hex(), "0x01")
+		if !errors.Is(err, test.Err) {
+			t.Errorf("%d (%s): expected err to be %v, got: %v", i, test.Description, test.Err, err)
+		}
+	}
+}